@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"bilinovel-downloader/downloader"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// errBatchFailed --from-file 中有小说下载失败
+var errBatchFailed = errors.New("some novels failed")
+
+// downloadTargets 从命令行参数或者 --from-file 中读取要下载的小说
+func downloadTargets(args []string) ([]*downloadTarget, error) {
+	if downloadArgs.fromFile != "" {
+		if len(args) > 0 {
+			return nil, usageErrorf("--from-file can not be used with url")
+		}
+		var r io.Reader = os.Stdin
+		if downloadArgs.fromFile != "-" {
+			file, err := os.Open(downloadArgs.fromFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open list file: %v", err)
+			}
+			defer file.Close()
+			r = file
+		}
+		return readTargets(r)
+	}
+
+	novelId, volumeId := downloadArgs.NovelId, downloadArgs.VolumeId
+	var site *downloader.Site
+	if len(args) > 0 {
+		resolved, err := downloader.Resolve(args[0])
+		if err != nil {
+			return nil, &usageError{err: err}
+		}
+		site, novelId, volumeId = resolved.Site, resolved.NovelId, resolved.VolumeId
+	} else {
+		var ok bool
+		site, ok = downloader.Lookup(downloadArgs.site)
+		if !ok {
+			return nil, usageErrorf("unknown site: %v", downloadArgs.site)
+		}
+	}
+	target, err := newDownloadTarget(site, novelId, volumeId, downloadArgs.volumes, downloadArgs.chapters, downloadArgs.latest)
+	if err != nil {
+		return nil, err
+	}
+	return []*downloadTarget{target}, nil
+}
+
+// readTargets 读取小说列表，每行一本小说的 ID 或者小说、卷的 URL，ID 属于 --site 指定的站点。
+// 之后可以跟 volumes=1-3,7、latest=2 和 chapters=5-12 选择卷和章节，没有指定时使用命令行中的选择。
+// 空行和以 # 开头的行会被忽略
+func readTargets(r io.Reader) ([]*downloadTarget, error) {
+	targets := make([]*downloadTarget, 0)
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		target, err := parseTargetLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		targets = append(targets, target)
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read list file: %v", err)
+	}
+	if len(targets) == 0 {
+		return nil, usageErrorf("no novel in list file")
+	}
+	return targets, nil
+}
+
+func parseTargetLine(line string) (*downloadTarget, error) {
+	fields := strings.Fields(line)
+	options := make(map[string]string)
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, usageErrorf("invalid option: %v", field)
+		}
+		if key != "volumes" && key != "chapters" && key != "latest" {
+			return nil, usageErrorf("unknown option: %v", key)
+		}
+		options[key] = value
+	}
+	volumes, chapters, latest := downloadArgs.volumes, downloadArgs.chapters, downloadArgs.latest
+	_, hasVolumes := options["volumes"]
+	_, hasLatest := options["latest"]
+	if hasVolumes || hasLatest {
+		// 行中的卷选择整体覆盖命令行中的 --volumes 和 --latest
+		volumes, latest = options["volumes"], 0
+		if hasLatest {
+			var err error
+			latest, err = strconv.Atoi(options["latest"])
+			if err != nil {
+				return nil, usageErrorf("invalid latest: %v", options["latest"])
+			}
+		}
+	}
+	if value, ok := options["chapters"]; ok {
+		chapters = value
+	}
+
+	novelId, err := strconv.Atoi(fields[0])
+	if err == nil {
+		site, ok := downloader.Lookup(downloadArgs.site)
+		if !ok {
+			return nil, usageErrorf("unknown site: %v", downloadArgs.site)
+		}
+		return newDownloadTarget(site, novelId, 0, volumes, chapters, latest)
+	}
+	resolved, err := downloader.Resolve(fields[0])
+	if err != nil {
+		return nil, &usageError{err: err}
+	}
+	return newDownloadTarget(resolved.Site, resolved.NovelId, resolved.VolumeId, volumes, chapters, latest)
+}
+
+// targetResult 一本小说的下载结果
+type targetResult struct {
+	target     *downloadTarget
+	downloaded int
+	err        error
+}
+
+// printBatchReport 输出 --from-file 中每本小说的下载结果，有小说失败或者没有开始下载时返回错误
+func printBatchReport(out io.Writer, total int, results []targetResult) error {
+	failed := 0
+	fmt.Fprintln(out, "Download report:")
+	for _, result := range results {
+		if result.err != nil {
+			failed++
+			fmt.Fprintf(out, "  FAILED %v: %d volumes downloaded, %v\n", result.target, result.downloaded, result.err)
+			continue
+		}
+		fmt.Fprintf(out, "  OK     %v: %d volumes downloaded\n", result.target, result.downloaded)
+	}
+	fmt.Fprintf(out, "%d of %d novels downloaded, %d failed", len(results)-failed, total, failed)
+	if skipped := total - len(results); skipped > 0 {
+		fmt.Fprintf(out, ", %d not started", skipped)
+	}
+	fmt.Fprintln(out)
+	if failed > 0 || len(results) < total {
+		return fmt.Errorf("%w: %d of %d novels failed", errBatchFailed, total-(len(results)-failed), total)
+	}
+	return nil
+}
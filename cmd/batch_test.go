@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"bilinovel-downloader/downloader"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// setSelection 设置命令行中的卷和章节选择，测试结束后恢复
+func setSelection(t *testing.T, site string, volumes string, chapters string, latest int) {
+	saved := downloadArgs
+	t.Cleanup(func() { downloadArgs = saved })
+	downloadArgs.site, downloadArgs.volumes, downloadArgs.chapters, downloadArgs.latest = site, volumes, chapters, latest
+}
+
+func TestParseTargetLine(t *testing.T) {
+	setSelection(t, "linovelib", "1-2", "", 0)
+
+	cases := []struct {
+		line     string
+		site     string
+		novelId  int
+		volumeId int
+		volumes  string
+		latest   int
+		chapters string
+	}{
+		// ID 属于 --site 指定的站点，使用命令行中的选择
+		{"2388", "linovelib", 2388, 0, "1-2", 0, ""},
+		{"https://www.bilinovel.com/novel/2388.html", "bilinovel", 2388, 0, "1-2", 0, ""},
+		// 行中的 volumes 和 latest 整体覆盖命令行中的选择
+		{"2388 latest=2", "linovelib", 2388, 0, "", 2, ""},
+		{"2388 volumes=3- latest=1", "linovelib", 2388, 0, "3-", 1, ""},
+		{"2388 volumes=1 chapters=5-12", "linovelib", 2388, 0, "1", 0, "5-12"},
+	}
+	for _, c := range cases {
+		target, err := parseTargetLine(c.line)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", c.line, err)
+		}
+		if target.site.Name != c.site || target.novelId != c.novelId || target.volumeId != c.volumeId ||
+			target.volumesSpec != c.volumes || target.latest != c.latest || target.chaptersSpec != c.chapters {
+			t.Errorf("unexpected target for %q: %+v", c.line, target)
+		}
+	}
+
+	for _, invalid := range []string{
+		"2388 volumes",
+		"2388 format=epub",
+		"2388 latest=x",
+		"https://example.com/novel/1.html",
+		// 单卷不能再选择卷
+		"https://www.bilinovel.com/novel/2388/vol_84522.html volumes=1",
+	} {
+		_, err := parseTargetLine(invalid)
+		if err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestReadTargets(t *testing.T) {
+	setSelection(t, "bilinovel", "", "", 0)
+
+	targets, err := readTargets(strings.NewReader(`
+# 连载中
+2388 latest=1
+
+https://tw.linovelib.com/novel/2388/vol_84522.html chapters=1-3
+`))
+	if err != nil {
+		t.Fatalf("failed to read targets: %v", err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("unexpected targets: %v", targets)
+	}
+	if targets[0].String() != "bilinovel novel 2388" || targets[1].String() != "linovelib-tw novel 2388 volume 84522" {
+		t.Fatalf("unexpected targets: %v, %v", targets[0], targets[1])
+	}
+
+	// 出错时带上行号
+	_, err = readTargets(strings.NewReader("2388\n\n2388 unknown=1\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 3:") {
+		t.Fatalf("expected error with line number, got %v", err)
+	}
+	_, err = readTargets(strings.NewReader("# empty\n\n"))
+	if err == nil {
+		t.Fatalf("expected error for empty list")
+	}
+}
+
+func TestPrintBatchReport(t *testing.T) {
+	site, _ := downloader.Lookup("bilinovel")
+	first := &downloadTarget{site: site, novelId: 2388}
+	second := &downloadTarget{site: site, novelId: 4126, volumeId: 236197}
+	results := []targetResult{
+		{target: first, downloaded: 3},
+		{target: second, downloaded: 0, err: fmt.Errorf("failed to get volume: %w", downloader.ErrNotFound)},
+	}
+
+	out := &bytes.Buffer{}
+	err := printBatchReport(out, 3, results)
+	if !errors.Is(err, errBatchFailed) {
+		t.Fatalf("expected batch failed error, got %v", err)
+	}
+	want := `Download report:
+  OK     bilinovel novel 2388: 3 volumes downloaded
+  FAILED bilinovel novel 4126 volume 236197: 0 volumes downloaded, failed to get volume: not found
+1 of 3 novels downloaded, 1 failed, 1 not started
+`
+	if out.String() != want {
+		t.Fatalf("unexpected report:\n%v", out.String())
+	}
+
+	out.Reset()
+	err = printBatchReport(out, 1, results[:1])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(out.String(), "1 of 1 novels downloaded, 0 failed\n") {
+		t.Fatalf("unexpected report:\n%v", out.String())
+	}
+}
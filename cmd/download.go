@@ -3,50 +3,109 @@ package cmd
 import (
 	"bilinovel-downloader/downloader"
 	"bilinovel-downloader/downloader/bilinovel"
+	_ "bilinovel-downloader/downloader/linovelib"
 	"bilinovel-downloader/epub"
+	"bilinovel-downloader/imageproc"
+	"bilinovel-downloader/imagestore"
 	"bilinovel-downloader/model"
+	"bilinovel-downloader/quality"
+	"bilinovel-downloader/session"
 	"bilinovel-downloader/text"
+	"bilinovel-downloader/utils"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/playwright-community/playwright-go"
 	"github.com/spf13/cobra"
 )
 
 var downloadCmd = &cobra.Command{
-	Use:   "download",
+	Use:   "download [url]",
 	Short: "Download a novel or volume",
-	Long:  "Download a novel or volume",
+	Long:  "Download a novel or volume, the novel and volume id can be parsed from the url",
+	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		slog.Info("Installing playwright")
-		err := playwright.Install(&playwright.RunOptions{
-			Browsers: []string{"chromium"},
-			Stdout:   io.Discard,
-		})
-		if err != nil {
-			slog.Error("failed to install playwright")
-			return
+		if downloadArgs.renderer == string(bilinovel.RendererPlaywright) {
+			slog.Info("Installing playwright")
+			err := playwright.Install(&playwright.RunOptions{
+				Browsers: []string{"chromium"},
+				Stdout:   io.Discard,
+			})
+			if err != nil {
+				slog.Error("failed to install playwright")
+				exitStatus = exitBrowser
+				return
+			}
 		}
 
-		err = runDownloadNovel()
+		// Ctrl-C 时取消下载，关闭浏览器后再退出
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		err := runDownloadNovel(ctx, args)
 		if err != nil {
 			slog.Error("failed to download novel", slog.Any("error", err))
+			exitStatus = exitCode(err)
 			return
 		}
 	},
 }
 
 type downloadCmdArgs struct {
-	NovelId     int `validate:"required"`
-	VolumeId    int `validate:"required"`
-	outputPath  string
-	outputType  string
-	concurrency int
-	debug       bool
+	NovelId            int `validate:"required"`
+	VolumeId           int `validate:"required"`
+	outputPath         string
+	outputType         string
+	concurrency        int
+	chapterConcurrency int
+	maxPages           int
+	debug              bool
+	renderer           string
+	site               string
+	siteDef            string
+
+	timeout       time.Duration
+	renderTimeout time.Duration
+	progress      bool
+	cacheDir      string
+	noCache       bool
+	imageDir      string
+	device        string
+	jpegQuality   int
+	update        bool
+
+	qualityFail     bool
+	minChapterChars int
+	maxPUARatio     float64
+
+	rateLimit      string
+	hostRateLimits []string
+
+	hostsFile     string
+	hostOverrides []string
+	dns           string
+
+	proxy       string
+	hostProxies []string
+
+	cookies string
+
+	volumes  string
+	chapters string
+	latest   int
+	fromFile string
 }
 
 var (
@@ -60,145 +119,680 @@ func init() {
 	downloadCmd.Flags().StringVarP(&downloadArgs.outputType, "output-type", "t", "epub", "output type, epub or text")
 	downloadCmd.Flags().BoolVar(&downloadArgs.debug, "debug", false, "debug mode")
 	downloadCmd.Flags().IntVar(&downloadArgs.concurrency, "concurrency", 3, "concurrency of downloading volumes")
+	downloadCmd.Flags().IntVar(&downloadArgs.chapterConcurrency, "chapter-concurrency", 4, "concurrency of downloading chapters in a volume")
+	downloadCmd.Flags().IntVar(&downloadArgs.maxPages, "max-pages", 0, "max number of browser tabs, defaults to chapter concurrency")
+	downloadCmd.Flags().StringVar(&downloadArgs.site, "site", "bilinovel", "site of the novel when using --novel-id instead of url, bilinovel, linovelib or linovelib-tw")
+	downloadCmd.Flags().StringVar(&downloadArgs.siteDef, "site-def", "", "site definition file (json or yaml), overrides built-in sites with the same name")
+	downloadCmd.Flags().StringVar(&downloadArgs.renderer, "renderer", string(bilinovel.RendererPlaywright), "chapter renderer, native, js or playwright")
+	downloadCmd.Flags().DurationVar(&downloadArgs.timeout, "timeout", 60*time.Second, "timeout of each http request, 0 means no timeout")
+	downloadCmd.Flags().DurationVar(&downloadArgs.renderTimeout, "render-timeout", bilinovel.DefaultRenderTimeout, "timeout of rendering a chapter page in browser or js engine")
+	downloadCmd.Flags().BoolVar(&downloadArgs.progress, "progress", true, "show progress bar instead of logs when output is a terminal")
+	downloadCmd.Flags().StringVar(&downloadArgs.cacheDir, "cache-dir", "", "directory of downloaded chapters, defaults to <output-path>/.cache")
+	downloadCmd.Flags().BoolVar(&downloadArgs.noCache, "no-cache", false, "do not cache downloaded chapters")
+	downloadCmd.Flags().StringVar(&downloadArgs.imageDir, "image-dir", "", "directory of downloaded images shared by all volumes, defaults to <output-path>/.images")
+	downloadCmd.Flags().StringVar(&downloadArgs.device, "device", "", fmt.Sprintf("process images for e-reader before packing epub, one of %v", strings.Join(imageproc.ProfileNames(), ", ")))
+	downloadCmd.Flags().IntVar(&downloadArgs.jpegQuality, "jpeg-quality", 0, "jpeg quality of processed images, overrides the device profile")
+	downloadCmd.Flags().BoolVar(&downloadArgs.update, "update", false, "update downloaded volumes with new chapters and chapters whose title changed")
+	downloadCmd.Flags().BoolVar(&downloadArgs.qualityFail, "quality-fail", false, "fail the download when the quality check finds garbled or incomplete chapters")
+	downloadCmd.Flags().IntVar(&downloadArgs.minChapterChars, "min-chapter-chars", quality.DefaultThresholds.MinChars, "chapters without images shorter than this are reported by the quality check")
+	downloadCmd.Flags().Float64Var(&downloadArgs.maxPUARatio, "max-pua-ratio", quality.DefaultThresholds.MaxPUARatio, "max ratio of private use area characters in a chapter allowed by the quality check")
+	downloadCmd.Flags().StringVar(&downloadArgs.rateLimit, "rate-limit", "5", "requests per second to each host as rate[:burst], 0 means no limit, slows down automatically on 429 and 503")
+	downloadCmd.Flags().StringArrayVar(&downloadArgs.hostRateLimits, "host-rate-limit", nil, "rate limit of a single host as host=rate[:burst], can be repeated")
+	downloadCmd.Flags().StringVar(&downloadArgs.hostsFile, "hosts", "", "hosts file overriding the address of hosts, also used by the browser, ignored for requests sent through a proxy")
+	downloadCmd.Flags().StringArrayVar(&downloadArgs.hostOverrides, "host-override", nil, "override the address of a host as host=address[:port], can be repeated, ignored for requests sent through a proxy")
+	downloadCmd.Flags().StringVar(&downloadArgs.dns, "dns", "", "dns server used to resolve hosts without override, defaults to the system resolver")
+	downloadCmd.Flags().StringVar(&downloadArgs.proxy, "proxy", "", "proxy of requests and browser, http, https, socks5 or socks5h url with optional user:password, defaults to HTTP_PROXY and HTTPS_PROXY for requests")
+	downloadCmd.Flags().StringArrayVar(&downloadArgs.hostProxies, "host-proxy", nil, "proxy of a host or a domain suffix starting with a dot as host=proxy, proxy can be direct, can be repeated")
+	downloadCmd.Flags().StringVar(&downloadArgs.cookies, "cookies", "", "cookies of a logged in session, netscape cookies.txt or json exported from browser")
+	downloadCmd.Flags().StringVar(&downloadArgs.volumes, "volumes", "", "volumes to download by series index or volume id, e.g. 1-3,7")
+	downloadCmd.Flags().StringVar(&downloadArgs.chapters, "chapters", "", "chapters of a single volume to download by index in the volume, e.g. 5-12")
+	downloadCmd.Flags().IntVar(&downloadArgs.latest, "latest", 0, "download only the latest n volumes")
+	downloadCmd.Flags().StringVar(&downloadArgs.fromFile, "from-file", "", "download every novel listed in the file, - reads from stdin")
+	downloadCmd.MarkFlagsMutuallyExclusive("volumes", "latest")
+	downloadCmd.MarkFlagsMutuallyExclusive("from-file", "novel-id")
+	downloadCmd.MarkFlagsMutuallyExclusive("from-file", "volume-id")
 	RootCmd.AddCommand(downloadCmd)
 }
 
-func runDownloadNovel() error {
-	downloader, err := bilinovel.New(bilinovel.BilinovelNewOption{
-		Concurrency: downloadArgs.concurrency,
-		Debug:       downloadArgs.debug,
-	})
+func runDownloadNovel(ctx context.Context, args []string) error {
+	if downloadArgs.siteDef != "" {
+		_, err := bilinovel.LoadSiteDefinitions(downloadArgs.siteDef)
+		if err != nil {
+			return err
+		}
+	}
+
+	targets, err := downloadTargets(args)
+	if err != nil {
+		return err
+	}
+	ds := &downloadSession{downloaders: make(map[*downloader.Site]downloader.Downloader)}
+
+	options := downloader.Options{
+		Concurrency:        downloadArgs.concurrency,
+		ChapterConcurrency: downloadArgs.chapterConcurrency,
+		MaxPages:           downloadArgs.maxPages,
+		Debug:              downloadArgs.debug,
+		Renderer:           downloadArgs.renderer,
+		Timeout:            downloadArgs.timeout,
+		RenderTimeout:      downloadArgs.renderTimeout,
+	}
+	err = parseRateLimits(&options)
+	if err != nil {
+		return err
+	}
+	err = parseHosts(&options)
+	if err != nil {
+		return err
+	}
+	err = parseProxies(&options)
+	if err != nil {
+		return err
+	}
+	if downloadArgs.cookies != "" {
+		options.Cookies, err = loadCookies(downloadArgs.cookies)
+		if err != nil {
+			return err
+		}
+	}
+	options.ImageDir = downloadArgs.imageDir
+	if options.ImageDir == "" {
+		options.ImageDir = filepath.Join(downloadArgs.outputPath, ".images")
+	}
+	ds.images = imagestore.New(options.ImageDir)
+	ds.thresholds = quality.DefaultThresholds
+	ds.thresholds.MinChars = downloadArgs.minChapterChars
+	ds.thresholds.MaxPUARatio = downloadArgs.maxPUARatio
+	if downloadArgs.device != "" {
+		profile, err := imageproc.Lookup(downloadArgs.device)
+		if err != nil {
+			return err
+		}
+		if downloadArgs.jpegQuality > 0 {
+			profile.Quality = downloadArgs.jpegQuality
+		}
+		ds.imageProfile = &profile
+	}
+	if !downloadArgs.noCache {
+		options.CacheDir = downloadArgs.cacheDir
+		if options.CacheDir == "" {
+			options.CacheDir = filepath.Join(downloadArgs.outputPath, ".cache")
+		}
+		ds.cache = downloader.NewChapterCache(options.CacheDir)
+	}
+	var bar *progressBar
+	if downloadArgs.progress && !downloadArgs.debug && isTerminal(os.Stdout) {
+		bar = newProgressBar(os.Stdout)
+		defer bar.Finish()
+		options.Progress = bar.Handle
+	}
+	ds.progress = options.Progress
+
+	// 所有小说共用同一个下载器和浏览器
+	results := make([]targetResult, 0, len(targets))
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+		ds.target = target
+		saved := ds.saved
+		// 限流和浏览器失败时重试，浏览器失败会重新创建下载器，已经缓存的章节不会重新下载
+		err := withRetry(ctx, func() error {
+			return ds.download(ctx, options)
+		})
+		if err != nil && len(targets) > 1 {
+			slog.Error("failed to download novel", slog.String("target", target.String()), slog.Any("error", err))
+		}
+		results = append(results, targetResult{target: target, downloaded: ds.saved - saved, err: err})
+	}
+	ds.close()
+
+	if bar != nil {
+		bar.Finish()
+	}
+	if downloadArgs.update {
+		printUpdateSummary(os.Stdout, ds.changes)
+	}
+	printQualityReports(os.Stdout, ds.reports)
+	if downloadArgs.fromFile != "" {
+		err = printBatchReport(os.Stdout, len(targets), results)
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+	} else {
+		err = ctx.Err()
+		if len(results) > 0 {
+			err = results[0].err
+		}
+		if targets[0].volumeId == 0 {
+			printDownloadSummary(os.Stdout, ds.saved, err)
+		}
+	}
+	return err
+}
+
+// downloadTarget 要下载的小说或者单卷，以及选择的卷和章节
+type downloadTarget struct {
+	site     *downloader.Site
+	novelId  int
+	volumeId int
+	// volumes 和 latest 选择下载整本小说时要下载的卷，都为空时下载所有卷
+	volumes     utils.Ranges
+	volumesSpec string
+	latest      int
+	// chapters 只下载单卷中的部分章节，为空时下载所有章节
+	chapters     downloader.ChapterFilter
+	chaptersSpec string
+}
+
+func (t *downloadTarget) String() string {
+	if t.volumeId != 0 {
+		return fmt.Sprintf("%v novel %v volume %v", t.site.Name, t.novelId, t.volumeId)
+	}
+	return fmt.Sprintf("%v novel %v", t.site.Name, t.novelId)
+}
+
+// newDownloadTarget 检查并解析卷和章节的选择
+func newDownloadTarget(site *downloader.Site, novelId int, volumeId int, volumes string, chapters string, latest int) (*downloadTarget, error) {
+	if novelId == 0 {
+		return nil, usageErrorf("novel id is required")
+	}
+	if latest < 0 {
+		return nil, usageErrorf("invalid latest: %v", latest)
+	}
+	target := &downloadTarget{site: site, novelId: novelId, volumeId: volumeId, latest: latest}
+	if volumes != "" {
+		var err error
+		target.volumes, err = utils.ParseRanges(volumes)
+		if err != nil {
+			return nil, usageErrorf("invalid volumes: %w", err)
+		}
+		target.volumesSpec = volumes
+	}
+	selecting := target.volumes != nil || target.latest > 0
+	if selecting && volumeId != 0 {
+		return nil, usageErrorf("--volumes and --latest can not be used when downloading a single volume")
+	}
+	if chapters != "" {
+		if !selecting && volumeId == 0 {
+			return nil, usageErrorf("--chapters requires a single volume")
+		}
+		if downloadArgs.update {
+			return nil, usageErrorf("--chapters can not be used with --update")
+		}
+		ranges, err := utils.ParseRanges(chapters)
+		if err != nil {
+			return nil, usageErrorf("invalid chapters: %w", err)
+		}
+		target.chapters = func(index int, _ *model.Chapter) bool {
+			return ranges.Contains(index)
+		}
+		target.chaptersSpec = chapters
+	}
+	return target, nil
+}
+
+// parseRateLimits 解析 --rate-limit 和 --host-rate-limit
+func parseRateLimits(options *downloader.Options) error {
+	var err error
+	options.RateLimit, err = utils.ParseRateLimit(downloadArgs.rateLimit)
 	if err != nil {
-		return fmt.Errorf("failed to create downloader: %v", err)
+		return &usageError{err: err}
 	}
-	// 确保在函数结束时关闭资源
-	defer func() {
-		if closeErr := downloader.Close(); closeErr != nil {
-			slog.Info("Failed to close downloader", slog.Any("error", closeErr))
+	options.HostRateLimits = make(map[string]utils.RateLimit)
+	for _, value := range downloadArgs.hostRateLimits {
+		host, limit, ok := strings.Cut(value, "=")
+		if !ok || host == "" {
+			return usageErrorf("invalid host rate limit: %v", value)
 		}
-	}()
+		options.HostRateLimits[host], err = utils.ParseRateLimit(limit)
+		if err != nil {
+			return &usageError{err: err}
+		}
+	}
+	return nil
+}
 
-	if downloadArgs.NovelId == 0 {
-		return fmt.Errorf("novel id is required")
+// parseHosts 解析 --hosts、--host-override 和 --dns，--host-override 优先于 hosts 文件
+func parseHosts(options *downloader.Options) error {
+	options.Hosts = make(map[string]string)
+	if downloadArgs.hostsFile != "" {
+		hosts, err := utils.LoadHosts(downloadArgs.hostsFile)
+		if err != nil {
+			return err
+		}
+		options.Hosts = hosts
+	}
+	for _, value := range downloadArgs.hostOverrides {
+		host, addr, ok := strings.Cut(value, "=")
+		if !ok || host == "" || addr == "" {
+			return usageErrorf("invalid host override: %v", value)
+		}
+		options.Hosts[strings.ToLower(host)] = addr
+	}
+	if downloadArgs.dns != "" {
+		options.Resolver = utils.NewDNSResolver(downloadArgs.dns)
 	}
+	return nil
+}
+
+// parseProxies 解析 --proxy 和 --host-proxy，检查代理地址的格式
+func parseProxies(options *downloader.Options) error {
+	if downloadArgs.proxy != "" {
+		_, err := utils.ParseProxy(downloadArgs.proxy)
+		if err != nil {
+			return &usageError{err: err}
+		}
+		options.Proxy = downloadArgs.proxy
+	}
+	options.HostProxies = make(map[string]string)
+	for _, value := range downloadArgs.hostProxies {
+		host, proxy, ok := strings.Cut(value, "=")
+		if !ok || host == "" || proxy == "" {
+			return usageErrorf("invalid host proxy: %v", value)
+		}
+		if proxy != utils.ProxyDirect {
+			_, err := utils.ParseProxy(proxy)
+			if err != nil {
+				return &usageError{err: err}
+			}
+		}
+		options.HostProxies[strings.ToLower(host)] = proxy
+	}
+	return nil
+}
 
-	if downloadArgs.VolumeId == 0 {
+// loadCookies 读取导入的会话，已经过期的 cookie 会被忽略并给出提示
+func loadCookies(path string) ([]*http.Cookie, error) {
+	cookies, err := session.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	expired := session.Expired(cookies, time.Now())
+	if len(expired) > 0 {
+		names := make([]string, len(expired))
+		for i, cookie := range expired {
+			names[i] = cookie.Name
+		}
+		slog.Warn("Some cookies have expired, export them again if restricted chapters fail", slog.String("cookies", strings.Join(names, ", ")))
+	}
+	return cookies, nil
+}
+
+// downloadSession 一次 download 命令的状态
+type downloadSession struct {
+	// downloader 当前下载的小说使用的下载器
+	downloader downloader.Downloader
+	// downloaders 每个站点的下载器，所有小说共用
+	downloaders map[*downloader.Site]downloader.Downloader
+	// target 当前下载的小说
+	target   *downloadTarget
+	progress downloader.ProgressFunc
+	// cache 章节缓存，为空时表示关闭了缓存
+	cache *downloader.ChapterCache
+	// images 图片库，与下载器使用同一个目录
+	images *imagestore.Store
+	// imageProfile 打包 EPUB 前的图片处理配置，为空时不处理
+	imageProfile *imageproc.Profile
+	// changes update 模式下每卷的变化
+	changes []volumeChange
+	// thresholds 质量检查的阈值
+	thresholds quality.Thresholds
+	// reports 本次检查的每卷的质量报告，包括使用 --quality-fail 时没有保存的卷
+	reports []*quality.Report
+	// saved 本次成功保存并打包的卷数
+	saved int
+}
+
+// download 下载当前的小说或者单卷，站点的下载器第一次使用时创建，浏览器失败后重新创建
+func (s *downloadSession) download(ctx context.Context, options downloader.Options) error {
+	site := s.target.site
+	dl, ok := s.downloaders[site]
+	if !ok {
+		var err error
+		dl, err = site.New(options)
+		if err != nil {
+			return fmt.Errorf("failed to create downloader: %w", err)
+		}
+		s.downloaders[site] = dl
+	}
+	s.downloader = dl
+
+	var err error
+	if s.target.volumeId == 0 {
 		// 下载整本小说
-		err := downloadNovel(downloader, downloadArgs.NovelId)
+		err = s.downloadNovel(ctx, s.target.novelId)
 		if err != nil {
-			return fmt.Errorf("failed to get novel: %v", err)
+			err = fmt.Errorf("failed to get novel: %w", err)
 		}
 	} else {
 		// 下载单卷
-		err = downloadVolume(downloader, downloadArgs.VolumeId)
+		err = s.downloadVolume(ctx, s.target.volumeId)
 		if err != nil {
-			return fmt.Errorf("failed to download volume: %v", err)
+			err = fmt.Errorf("failed to download volume: %w", err)
 		}
 	}
+	if errors.Is(err, downloader.ErrBrowser) {
+		s.closeDownloader(site)
+	}
+	return err
+}
+
+// closeDownloader 关闭站点的下载器，下次使用时重新创建
+func (s *downloadSession) closeDownloader(site *downloader.Site) {
+	dl, ok := s.downloaders[site]
+	if !ok {
+		return
+	}
+	delete(s.downloaders, site)
+	if err := dl.Close(); err != nil {
+		slog.Info("Failed to close downloader", slog.Any("error", err))
+	}
+}
 
-	return nil
+// close 关闭所有下载器
+func (s *downloadSession) close() {
+	for site := range s.downloaders {
+		s.closeDownloader(site)
+	}
 }
 
-func downloadNovel(downloader downloader.Downloader, novelId int) error {
-	novelInfo, err := downloader.GetNovel(novelId, true, nil)
-	if err != nil {
+// volumeJsonPath 返回卷的 JSON 文件路径，镜像站的小说和卷 ID 可能相同，文件名中包含站点名
+func (s *downloadSession) volumeJsonPath(novelId int, volumeId int) string {
+	return filepath.Join(downloadArgs.outputPath, fmt.Sprintf("volume-%v-%d-%d.json", s.target.site.Name, novelId, volumeId))
+}
+
+func (s *downloadSession) downloadNovel(ctx context.Context, novelId int) error {
+	novelInfo, err := s.downloader.GetNovel(ctx, novelId, true, nil)
+	var partial *downloader.PartialError
+	if err != nil && !errors.As(err, &partial) {
 		return fmt.Errorf("failed to get novel info: %w", err)
 	}
+	// failed 记录所有失败的卷，其他卷下载完成后一起返回
+	failed := &downloader.PartialError{NovelId: novelId}
+	selected, err := s.selectVolumes(novelInfo.Volumes, partial)
+	if err != nil {
+		return err
+	}
+	if s.target.chapters != nil {
+		if len(selected) != 1 {
+			return fmt.Errorf("--chapters requires a single volume, but %d volumes are selected", len(selected))
+		}
+		for volumeId := range selected {
+			return s.downloadVolume(ctx, volumeId)
+		}
+	}
 	skipVolumes := make([]int, 0)
+	if partial != nil {
+		// 读取目录时已经失败的卷不再重复获取
+		for _, volumeErr := range partial.Volumes {
+			if selected == nil || selected[volumeErr.VolumeId] {
+				failed.Volumes = append(failed.Volumes, volumeErr)
+			}
+			skipVolumes = append(skipVolumes, volumeErr.VolumeId)
+		}
+	}
 	for _, volume := range novelInfo.Volumes {
-		jsonPath := filepath.Join(downloadArgs.outputPath, fmt.Sprintf("volume-%d-%d.json", downloadArgs.NovelId, volume.Id))
+		if selected != nil && !selected[volume.Id] {
+			skipVolumes = append(skipVolumes, volume.Id)
+			continue
+		}
+		jsonPath := s.volumeJsonPath(novelId, volume.Id)
 		err = os.MkdirAll(filepath.Dir(jsonPath), 0755)
 		if err != nil {
 			return fmt.Errorf("failed to create directory: %v", err)
 		}
 		_, err = os.Stat(jsonPath)
-		if err == nil {
-			// 已经下载
-			skipVolumes = append(skipVolumes, volume.Id)
+		if err != nil {
+			continue
+		}
+		// 已经下载
+		skipVolumes = append(skipVolumes, volume.Id)
+		if downloadArgs.update {
+			err = s.updateVolume(ctx, jsonPath, volume)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				failed.Volumes = append(failed.Volumes, &downloader.VolumeError{VolumeId: volume.Id, Err: fmt.Errorf("failed to update volume: %w", err)})
+			}
 		}
 	}
-	novel, err := downloader.GetNovel(novelId, false, skipVolumes)
-	if err != nil {
+	novel, err := s.downloader.GetNovel(ctx, novelId, false, skipVolumes)
+	partial = nil
+	if err != nil && !errors.As(err, &partial) {
 		return fmt.Errorf("failed to download novel: %w", err)
 	}
+	if partial != nil {
+		failed.Volumes = append(failed.Volumes, partial.Volumes...)
+	}
 	for _, volume := range novel.Volumes {
-		jsonPath := filepath.Join(downloadArgs.outputPath, fmt.Sprintf("volume-%d-%d.json", downloadArgs.NovelId, volume.Id))
-		err = os.MkdirAll(filepath.Dir(jsonPath), 0755)
-		if err != nil {
-			return fmt.Errorf("failed to create directory: %v", err)
-		}
-		jsonFile, err := os.Create(jsonPath)
-		if err != nil {
-			return fmt.Errorf("failed to create json file: %v", err)
+		err = s.saveVolume(s.volumeJsonPath(novelId, volume.Id), volume)
+		if errors.Is(err, errQualityCheck) {
+			// 质量检查失败的卷与下载失败的卷一样在汇总中列出，不影响其他卷
+			failed.Volumes = append(failed.Volumes, &downloader.VolumeError{VolumeId: volume.Id, SeriesIdx: volume.SeriesIdx, Err: err})
+			continue
 		}
-		err = json.NewEncoder(jsonFile).Encode(volume)
 		if err != nil {
-			return fmt.Errorf("failed to encode json file: %v", err)
+			return err
 		}
-		switch downloadArgs.outputType {
-		case "epub":
-			err = epub.PackVolumeToEpub(volume, downloadArgs.outputPath, downloader.GetStyleCSS(), downloader.GetExtraFiles())
-			if err != nil {
-				return fmt.Errorf("failed to pack volume: %v", err)
-			}
-		case "text":
-			err = text.PackVolumeToText(volume, downloadArgs.outputPath)
-			if err != nil {
-				return fmt.Errorf("failed to pack volume: %v", err)
-			}
+		if downloadArgs.update {
+			s.recordChange(volumeChange{volume: volume, added: len(volume.Chapters)})
 		}
 	}
+	if len(failed.Volumes) > 0 {
+		return failed
+	}
 	return nil
 }
 
-func downloadVolume(downloader downloader.Downloader, volumeId int) error {
-	jsonPath := filepath.Join(downloadArgs.outputPath, fmt.Sprintf("volume-%d-%d.json", downloadArgs.NovelId, volumeId))
+// selectVolumes 按 --volumes 和 --latest 选择要下载的卷，--volumes 中的数字可以是目录中的序号或者卷 ID，区间只表示序号。
+// 返回选中的卷 ID，没有选择时返回 nil
+func (s *downloadSession) selectVolumes(volumes []*model.Volume, partial *downloader.PartialError) (map[int]bool, error) {
+	target := s.target
+	if target.volumes == nil && target.latest == 0 {
+		return nil, nil
+	}
+	// 读取目录时失败的卷也参与选择
+	type catalogEntry struct {
+		id        int
+		seriesIdx int
+	}
+	entries := make([]catalogEntry, 0, len(volumes))
+	for _, volume := range volumes {
+		entries = append(entries, catalogEntry{id: volume.Id, seriesIdx: volume.SeriesIdx})
+	}
+	if partial != nil {
+		for _, volumeErr := range partial.Volumes {
+			entries = append(entries, catalogEntry{id: volumeErr.VolumeId, seriesIdx: volumeErr.SeriesIdx})
+		}
+	}
+	slices.SortFunc(entries, func(a, b catalogEntry) int {
+		return a.seriesIdx - b.seriesIdx
+	})
+	if target.latest > 0 {
+		entries = entries[max(0, len(entries)-target.latest):]
+	}
+	selected := make(map[int]bool)
+	for _, entry := range entries {
+		// 卷 ID 只匹配单独的数字，否则 3- 这样的区间会匹配所有的卷 ID
+		if target.volumes == nil || target.volumes.Contains(entry.seriesIdx) || target.volumes.ContainsExact(entry.id) {
+			selected[entry.id] = true
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no volume matches %v: %w", target.volumesSpec, downloader.ErrNotFound)
+	}
+	return selected, nil
+}
+
+func (s *downloadSession) downloadVolume(ctx context.Context, volumeId int) error {
+	if s.target.chapters != nil {
+		return s.downloadChapters(ctx, volumeId)
+	}
+	novelId := s.target.novelId
+	jsonPath := s.volumeJsonPath(novelId, volumeId)
 	err := os.MkdirAll(filepath.Dir(jsonPath), 0755)
 	if err != nil {
 		return fmt.Errorf("failed to create directory: %v", err)
 	}
 	_, err = os.Stat(jsonPath)
-	volume := &model.Volume{}
 	if err != nil {
-		if os.IsNotExist(err) {
-			volume, err = downloader.GetVolume(downloadArgs.NovelId, volumeId, false)
-			if err != nil {
-				return fmt.Errorf("failed to get volume: %v", err)
-			}
-			jsonFile, err := os.Create(jsonPath)
-			if err != nil {
-				return fmt.Errorf("failed to create json file: %v", err)
-			}
-			err = json.NewEncoder(jsonFile).Encode(volume)
-			if err != nil {
-				return fmt.Errorf("failed to encode json file: %v", err)
-			}
-		} else {
-			return fmt.Errorf("failed to get volume: %v", err)
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to get volume: %w", err)
 		}
-	} else {
-		jsonFile, err := os.Open(jsonPath)
+		volume, err := s.downloader.GetVolume(ctx, novelId, volumeId, false, nil)
 		if err != nil {
-			return fmt.Errorf("failed to open json file: %v", err)
+			return fmt.Errorf("failed to get volume: %w", err)
 		}
-		defer jsonFile.Close()
-		err = json.NewDecoder(jsonFile).Decode(volume)
+		if downloadArgs.update {
+			s.recordChange(volumeChange{volume: volume, added: len(volume.Chapters)})
+		}
+		return s.saveVolume(jsonPath, volume)
+	}
+
+	if downloadArgs.update {
+		latest, err := s.downloader.GetVolume(ctx, novelId, volumeId, true, nil)
 		if err != nil {
-			return fmt.Errorf("failed to decode json file: %v", err)
+			return fmt.Errorf("failed to get volume: %w", err)
 		}
+		return s.updateVolume(ctx, jsonPath, latest)
+	}
+
+	volume, err := s.loadVolume(jsonPath)
+	if err != nil {
+		return err
+	}
+	return s.packVolume(volume)
+}
+
+// downloadChapters 下载单卷中的部分章节，不保存卷的 JSON，避免之后被当作已经下载的完整的卷，
+// 打包的标题带上章节范围，不会覆盖完整的卷
+func (s *downloadSession) downloadChapters(ctx context.Context, volumeId int) error {
+	target := s.target
+	volume, err := s.downloader.GetVolume(ctx, target.novelId, volumeId, false, target.chapters)
+	if err != nil {
+		return fmt.Errorf("failed to get volume: %w", err)
+	}
+	if len(volume.Chapters) == 0 {
+		return fmt.Errorf("no chapter of volume %v matches %v: %w", volumeId, target.chaptersSpec, downloader.ErrNotFound)
+	}
+	volume.Title = fmt.Sprintf("%v (%v)", volume.Title, target.chaptersSpec)
+	err = s.checkVolume(volume)
+	if err != nil {
+		return err
+	}
+	return s.packVolume(volume)
+}
+
+// loadVolume 读取已下载的卷，旧版本内嵌在 JSON 中的图片会迁移到图片库
+func (s *downloadSession) loadVolume(jsonPath string) (*model.Volume, error) {
+	jsonFile, err := os.Open(jsonPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open json file: %v", err)
+	}
+	defer jsonFile.Close()
+	volume := &model.Volume{}
+	err = json.NewDecoder(jsonFile).Decode(volume)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode json file: %v", err)
+	}
+	err = s.images.MigrateVolume(volume)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate images: %v", err)
+	}
+	return volume, nil
+}
+
+// saveVolume 检查内容质量后保存卷的 JSON 并打包
+func (s *downloadSession) saveVolume(jsonPath string, volume *model.Volume) error {
+	err := s.checkVolume(volume)
+	if err != nil {
+		return err
 	}
+	jsonFile, err := os.Create(jsonPath)
+	if err != nil {
+		return fmt.Errorf("failed to create json file: %v", err)
+	}
+	defer jsonFile.Close()
+	err = json.NewEncoder(jsonFile).Encode(volume)
+	if err != nil {
+		return fmt.Errorf("failed to encode json file: %v", err)
+	}
+	err = s.packVolume(volume)
+	if err != nil {
+		return err
+	}
+	s.saved++
+	return nil
+}
+
+// checkVolume 检查卷的内容质量，有问题的章节从章节缓存中移除，下次运行时重新下载。
+// 使用 --quality-fail 时有问题的卷不会保存和打包
+func (s *downloadSession) checkVolume(volume *model.Volume) error {
+	report := quality.Check(volume, s.thresholds)
+	s.reports = append(s.reports, report)
+	if report.OK() {
+		return nil
+	}
+	if s.cache != nil {
+		for _, issue := range report.Issues {
+			err := s.cache.Remove(s.target.site.Name, volume.NovelId, volume.Id, issue.ChapterId)
+			if err != nil {
+				slog.Warn("Failed to remove cached chapter", slog.Int("chapterId", issue.ChapterId), slog.Any("error", err))
+			}
+		}
+	}
+	if downloadArgs.qualityFail {
+		return fmt.Errorf("%w: %v (%v) has %d issues", errQualityCheck, volume.Title, volume.Id, len(report.Issues))
+	}
+	return nil
+}
+
+// printDownloadSummary 输出下载整本小说的结果，部分卷失败时列出失败的卷和原因
+func printDownloadSummary(out io.Writer, downloaded int, err error) {
+	var partial *downloader.PartialError
+	if err != nil && !errors.As(err, &partial) {
+		return
+	}
+	fmt.Fprintf(out, "Downloaded %d volumes\n", downloaded)
+	if partial == nil {
+		return
+	}
+	fmt.Fprintf(out, "Failed %d volumes:\n", len(partial.Volumes))
+	for _, volumeErr := range partial.Volumes {
+		fmt.Fprintf(out, "  %v: %v\n", volumeErr.VolumeId, volumeErr.Err)
+	}
+}
+
+// printQualityReports 输出有问题的卷的质量报告，返回有问题的卷数
+func printQualityReports(out io.Writer, reports []*quality.Report) int {
+	failed := 0
+	for _, report := range reports {
+		if !report.OK() {
+			failed++
+			fmt.Fprintln(out, report)
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(out, "Quality check: %d of %d volumes have issues\n", failed, len(reports))
+	}
+	return failed
+}
 
+func (s *downloadSession) packVolume(volume *model.Volume) error {
 	switch downloadArgs.outputType {
 	case "epub":
-		err = epub.PackVolumeToEpub(volume, downloadArgs.outputPath, downloader.GetStyleCSS(), downloader.GetExtraFiles())
+		err := epub.PackVolumeToEpub(volume, downloadArgs.outputPath, s.downloader.GetStyleCSS(), s.downloader.GetExtraFiles(), s.images, s.imageProfile)
 		if err != nil {
 			return fmt.Errorf("failed to pack volume: %v", err)
 		}
 	case "text":
-		err = text.PackVolumeToText(volume, downloadArgs.outputPath)
+		err := text.PackVolumeToText(volume, downloadArgs.outputPath)
 		if err != nil {
 			return fmt.Errorf("failed to pack volume: %v", err)
 		}
 	}
+	emitVolumePacked(s.progress, volume)
 	return nil
 }
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bilinovel-downloader/downloader"
+	"bilinovel-downloader/model"
+	"errors"
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSelectVolumes(t *testing.T) {
+	volumes := []*model.Volume{
+		{Id: 84522, SeriesIdx: 1},
+		{Id: 84523, SeriesIdx: 2},
+		{Id: 84530, SeriesIdx: 4},
+	}
+	// 读取目录时失败的卷同样可以被选中
+	partial := &downloader.PartialError{Volumes: []*downloader.VolumeError{{VolumeId: 84525, SeriesIdx: 3}}}
+
+	cases := []struct {
+		volumes string
+		latest  int
+		want    []int
+	}{
+		{"", 0, nil},
+		{"1-2", 0, []int{84522, 84523}},
+		// 开放区间只匹配序号，不会匹配所有的卷 ID
+		{"3-", 0, []int{84525, 84530}},
+		// 单独的数字可以是卷 ID
+		{"84523,1", 0, []int{84522, 84523}},
+		{"", 2, []int{84525, 84530}},
+		{"1-3", 2, []int{84525}},
+	}
+	for _, c := range cases {
+		target, err := newDownloadTarget(nil, 2388, 0, c.volumes, "", c.latest)
+		if err != nil {
+			t.Fatalf("failed to create target: %v", err)
+		}
+		ds := &downloadSession{target: target}
+		selected, err := ds.selectVolumes(volumes, partial)
+		if err != nil {
+			t.Fatalf("failed to select volumes %q latest %v: %v", c.volumes, c.latest, err)
+		}
+		got := slices.Sorted(maps.Keys(selected))
+		if !slices.Equal(got, c.want) {
+			t.Errorf("unexpected volumes for %q latest %v: %v, want %v", c.volumes, c.latest, got, c.want)
+		}
+	}
+
+	target, err := newDownloadTarget(nil, 2388, 0, "10-", "", 0)
+	if err != nil {
+		t.Fatalf("failed to create target: %v", err)
+	}
+	_, err = (&downloadSession{target: target}).selectVolumes(volumes, partial)
+	if !errors.Is(err, downloader.ErrNotFound) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestVolumeJsonPath(t *testing.T) {
+	// 镜像站的小说和卷 ID 相同时，JSON 文件不会互相覆盖
+	paths := make(map[string]bool)
+	for _, name := range []string{"bilinovel", "linovelib", "linovelib-tw"} {
+		ds := &downloadSession{target: &downloadTarget{site: &downloader.Site{Name: name}, novelId: 2388}}
+		paths[ds.volumeJsonPath(2388, 84522)] = true
+	}
+	if len(paths) != 3 {
+		t.Fatalf("expected 3 distinct paths, got %v", slices.Collect(maps.Keys(paths)))
+	}
+}
+
+func TestSaveVolumeCountsSaved(t *testing.T) {
+	outputType, qualityFail := downloadArgs.outputType, downloadArgs.qualityFail
+	downloadArgs.outputType, downloadArgs.qualityFail = "", true
+	defer func() { downloadArgs.outputType, downloadArgs.qualityFail = outputType, qualityFail }()
+
+	ds := &downloadSession{target: &downloadTarget{site: &downloader.Site{Name: "bilinovel"}, novelId: 1}}
+	dir := t.TempDir()
+	ok := &model.Volume{Id: 1, NovelId: 1, Chapters: []*model.Chapter{{Id: 1, Content: &model.ChaperContent{Html: "<p>正文</p>"}}}}
+	missing := &model.Volume{Id: 2, NovelId: 1, Chapters: []*model.Chapter{{Id: 2}}}
+
+	err := ds.saveVolume(filepath.Join(dir, "1.json"), ok)
+	if err != nil {
+		t.Fatalf("failed to save volume: %v", err)
+	}
+	// --quality-fail 拒绝的卷有质量报告，但不计入保存的卷数
+	err = ds.saveVolume(filepath.Join(dir, "2.json"), missing)
+	if !errors.Is(err, errQualityCheck) {
+		t.Fatalf("expected quality check error, got %v", err)
+	}
+	if ds.saved != 1 || len(ds.reports) != 2 {
+		t.Fatalf("unexpected saved volumes %v and reports %v", ds.saved, len(ds.reports))
+	}
+}
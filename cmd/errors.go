@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"bilinovel-downloader/downloader"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// 命令的退出码，脚本可以根据退出码判断失败的原因
+const (
+	exitOK        = 0
+	exitFailure   = 1
+	exitUsage     = 2
+	exitNotFound  = 3
+	exitRateLimit = 4
+	exitChallenge = 5
+	exitParse     = 6
+	exitBrowser   = 7
+	exitLogin     = 8
+	exitQuality   = 9
+	exitNetwork   = 10
+	exitPartial   = 11
+	exitCanceled  = 130
+)
+
+// errQualityCheck 使用 --quality-fail 时质量检查发现了问题
+var errQualityCheck = errors.New("quality check failed")
+
+// usageError 命令行参数或者小说列表中的选择无效，与 cobra 的参数错误一样使用 exitUsage
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string { return e.err.Error() }
+func (e *usageError) Unwrap() error { return e.err }
+
+func usageErrorf(format string, args ...any) error {
+	return &usageError{err: fmt.Errorf(format, args...)}
+}
+
+// exitStatus 命令结束时的退出码
+var exitStatus = exitOK
+
+// exitCode 根据错误的分类返回退出码
+func exitCode(err error) int {
+	var netErr net.Error
+	var partial *downloader.PartialError
+	var usage *usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, context.Canceled):
+		return exitCanceled
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.As(err, &partial), errors.Is(err, errBatchFailed):
+		// 部分卷或者小说失败时，失败的原因在汇总中列出
+		return exitPartial
+	case errors.Is(err, downloader.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, downloader.ErrRateLimited):
+		return exitRateLimit
+	case errors.Is(err, downloader.ErrChallenge):
+		return exitChallenge
+	case errors.Is(err, downloader.ErrParse):
+		return exitParse
+	case errors.Is(err, downloader.ErrBrowser):
+		return exitBrowser
+	case errors.Is(err, downloader.ErrLoginRequired), errors.Is(err, downloader.ErrSessionExpired):
+		return exitLogin
+	case errors.Is(err, errQualityCheck):
+		return exitQuality
+	case errors.As(err, &netErr):
+		return exitNetwork
+	default:
+		return exitFailure
+	}
+}
+
+// retryPolicy 一类失败的重试次数和每次重试前的等待时间，等待时间随重试次数线性增加
+type retryPolicy struct {
+	attempts int
+	delay    time.Duration
+}
+
+// retryPolicyFor 限流和网络错误等待后重试，浏览器失败重新启动浏览器后重试一次，
+// 不存在、页面结构变化、反爬验证和需要登录的失败重试也不会成功
+func retryPolicyFor(err error) retryPolicy {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return retryPolicy{}
+	case errors.Is(err, downloader.ErrRateLimited):
+		return retryPolicy{attempts: 3, delay: time.Minute}
+	case errors.Is(err, downloader.ErrBrowser):
+		return retryPolicy{attempts: 1, delay: 5 * time.Second}
+	case errors.As(err, &netErr):
+		return retryPolicy{attempts: 2, delay: 10 * time.Second}
+	default:
+		return retryPolicy{}
+	}
+}
+
+// withRetry 按失败的分类重试 fn，ctx 取消时立即返回
+func withRetry(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		policy := retryPolicyFor(err)
+		if err == nil || attempt > policy.attempts || ctx.Err() != nil {
+			return err
+		}
+		delay := policy.delay * time.Duration(attempt)
+		slog.Warn("Download failed, retrying", slog.Int("attempt", attempt), slog.Duration("delay", delay), slog.Any("error", err))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"bilinovel-downloader/downloader"
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	cases := []struct {
+		err  error
+		code int
+	}{
+		{nil, exitOK},
+		{fmt.Errorf("failed to get volume: %w", &downloader.ParseError{Url: "https://www.bilinovel.com/novel/1/catalog", Reason: "failed to parse html: unexpected EOF"}), exitParse},
+		{fmt.Errorf("failed to get novel: %w", &downloader.PartialError{NovelId: 1}), exitPartial},
+		{fmt.Errorf("%w: 1 volumes have issues", errQualityCheck), exitQuality},
+		{context.Canceled, exitCanceled},
+		{fmt.Errorf("line 2: %w", usageErrorf("unknown site: %v", "example")), exitUsage},
+		{fmt.Errorf("failed to create epub"), exitFailure},
+	}
+	for _, c := range cases {
+		if code := exitCode(c.err); code != c.code {
+			t.Errorf("unexpected exit code for %v: %v, want %v", c.err, code, c.code)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"bilinovel-downloader/epub"
 	"fmt"
+	"log/slog"
 
 	"github.com/spf13/cobra"
 )
@@ -19,7 +20,13 @@ var packCmd = &cobra.Command{
 	Use:   "pack",
 	Short: "pack a epub file from directory",
 	Long:  "pack a epub file from directory",
-	RunE:  runPackage,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runPackage(cmd, args)
+		if err != nil {
+			slog.Error("failed to pack epub", slog.Any("error", err))
+			exitStatus = exitCode(err)
+		}
+	},
 }
 
 func init() {
@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"bilinovel-downloader/downloader"
+	"bilinovel-downloader/model"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	progressBarWidth    = 30
+	progressTitleWidth  = 24
+	progressMaxVolumes  = 8
+	progressRedrawDelay = 100 * time.Millisecond
+)
+
+// progressKey 批量下载时不同小说的卷 ID 可能相同，按小说和卷区分进度
+type progressKey struct {
+	novelId  int
+	volumeId int
+}
+
+type volumeProgress struct {
+	title    string
+	total    int
+	done     int
+	finished bool
+	packed   bool
+}
+
+// progressBar 在终端中以多行进度条展示下载进度，第一行为总体进度和预计剩余时间，之后每行为一个正在下载的卷
+type progressBar struct {
+	mu  sync.Mutex
+	out io.Writer
+
+	start      time.Time
+	novelTitle string
+	// novelVolumes 每本小说的卷数
+	novelVolumes map[int]int
+	volumes      map[progressKey]*volumeProgress
+	order        []progressKey
+	failures     int
+
+	lines    int
+	lastDraw time.Time
+	finished bool
+}
+
+func newProgressBar(out io.Writer) *progressBar {
+	return &progressBar{
+		out:          out,
+		start:        time.Now(),
+		novelVolumes: make(map[int]int),
+		volumes:      make(map[progressKey]*volumeProgress),
+	}
+}
+
+// isTerminal 判断输出是否为终端，重定向到文件时不使用进度条
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func (p *progressBar) volume(event downloader.Event) *volumeProgress {
+	key := progressKey{novelId: event.NovelId, volumeId: event.VolumeId}
+	v, ok := p.volumes[key]
+	if !ok {
+		v = &volumeProgress{}
+		p.volumes[key] = v
+		p.order = append(p.order, key)
+	}
+	return v
+}
+
+// Handle 处理进度事件，可以直接作为 downloader.ProgressFunc 使用
+func (p *progressBar) Handle(event downloader.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.finished {
+		return
+	}
+
+	force := false
+	switch event.Type {
+	case downloader.EventNovelDiscovered:
+		p.novelTitle = event.Title
+		p.novelVolumes[event.NovelId] = event.Total
+	case downloader.EventVolumeStarted:
+		// 重试的卷重新开始计数
+		v := p.volume(event)
+		v.title = event.Title
+		v.total = event.Total
+		v.done = 0
+		v.finished = false
+		force = true
+	case downloader.EventChapterFinished:
+		p.volume(event).done++
+	case downloader.EventVolumeFinished:
+		v := p.volume(event)
+		v.finished = true
+		v.done = v.total
+		force = true
+	case downloader.EventVolumePacked:
+		v := p.volume(event)
+		if v.title == "" {
+			v.title = event.Title
+		}
+		v.packed = true
+		force = true
+	case downloader.EventFailed:
+		p.failures++
+		p.printAbove(formatFailure(event))
+		return
+	default:
+		return
+	}
+
+	if force || time.Since(p.lastDraw) >= progressRedrawDelay {
+		p.draw()
+	}
+}
+
+// Finish 输出最终状态，之后的输出不会被进度条覆盖，重复调用时忽略
+func (p *progressBar) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.finished {
+		return
+	}
+	p.draw()
+	p.lines = 0
+	p.finished = true
+}
+
+func formatFailure(event downloader.Event) string {
+	target := fmt.Sprintf("volume %v", event.VolumeId)
+	switch {
+	case event.Url != "":
+		target = fmt.Sprintf("image %v", event.Url)
+	case event.ChapterId != 0:
+		target = fmt.Sprintf("chapter %v page %v", event.ChapterId, event.Page)
+	}
+	return fmt.Sprintf("failed: %v: %v", target, event.Err)
+}
+
+// printAbove 清除进度条，输出一行消息后重新绘制进度条
+func (p *progressBar) printAbove(message string) {
+	p.clear()
+	fmt.Fprintln(p.out, message)
+	p.lines = 0
+	p.draw()
+}
+
+func (p *progressBar) clear() {
+	if p.lines > 0 {
+		fmt.Fprintf(p.out, "\x1b[%dA\x1b[J", p.lines)
+	}
+}
+
+func (p *progressBar) draw() {
+	lines := []string{p.summary()}
+	shown := 0
+	for _, key := range p.order {
+		v := p.volumes[key]
+		if v.packed || shown >= progressMaxVolumes {
+			continue
+		}
+		shown++
+		lines = append(lines, fmt.Sprintf("  %v %v %v/%v", truncate(v.title, progressTitleWidth), bar(v.done, v.total), v.done, v.total))
+	}
+
+	p.clear()
+	fmt.Fprint(p.out, strings.Join(lines, "\n")+"\n")
+	p.lines = len(lines)
+	p.lastDraw = time.Now()
+}
+
+func (p *progressBar) summary() string {
+	totalVolumes := 0
+	for _, n := range p.novelVolumes {
+		totalVolumes += n
+	}
+	totalVolumes = max(totalVolumes, len(p.volumes))
+	packed, started, done, total := 0, 0, 0, 0
+	for _, v := range p.volumes {
+		if v.packed {
+			packed++
+		}
+		if v.total > 0 {
+			started++
+		}
+		done += v.done
+		total += v.total
+	}
+	// 未开始的卷按已开始的卷的平均章节数估算
+	if started > 0 && totalVolumes > started {
+		total += total / started * (totalVolumes - started)
+	}
+
+	elapsed := time.Since(p.start)
+	eta := "--"
+	if done > 0 && total > done {
+		eta = (elapsed / time.Duration(done) * time.Duration(total-done)).Round(time.Second).String()
+	} else if total > 0 && done >= total {
+		eta = "0s"
+	}
+
+	title := p.novelTitle
+	if title == "" {
+		title = "download"
+	}
+	summary := fmt.Sprintf("%v %v volumes %v/%v chapters %v/%v elapsed %v eta %v",
+		truncate(title, progressTitleWidth), bar(done, total), packed, totalVolumes, done, total, elapsed.Round(time.Second), eta)
+	if p.failures > 0 {
+		summary += fmt.Sprintf(" failures %v", p.failures)
+	}
+	return summary
+}
+
+func bar(done int, total int) string {
+	filled := 0
+	if total > 0 {
+		filled = min(done*progressBarWidth/total, progressBarWidth)
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", progressBarWidth-filled) + "]"
+}
+
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// emitVolumePacked 打包完成后通知进度条
+func emitVolumePacked(progress downloader.ProgressFunc, volume *model.Volume) {
+	progress.Emit(downloader.Event{
+		Type:     downloader.EventVolumePacked,
+		NovelId:  volume.NovelId,
+		VolumeId: volume.Id,
+		Title:    volume.Title,
+	})
+}
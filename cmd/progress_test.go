@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"bilinovel-downloader/downloader"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestProgressBarHandle(t *testing.T) {
+	p := newProgressBar(io.Discard)
+	chapters := func(novelId int, volumeId int, n int) {
+		for range n {
+			p.Handle(downloader.Event{Type: downloader.EventChapterFinished, NovelId: novelId, VolumeId: volumeId})
+		}
+	}
+
+	p.Handle(downloader.Event{Type: downloader.EventNovelDiscovered, NovelId: 1, Title: "a", Total: 2})
+	p.Handle(downloader.Event{Type: downloader.EventVolumeStarted, NovelId: 1, VolumeId: 10, Total: 3})
+	chapters(1, 10, 2)
+	// 重试的卷从零开始计数
+	p.Handle(downloader.Event{Type: downloader.EventVolumeStarted, NovelId: 1, VolumeId: 10, Total: 3})
+	chapters(1, 10, 3)
+	if v := p.volumes[progressKey{1, 10}]; v.done != 3 || v.total != 3 {
+		t.Fatalf("unexpected progress after retry: %v/%v", v.done, v.total)
+	}
+
+	// 批量下载时另一本小说的同 ID 卷单独计数
+	p.Handle(downloader.Event{Type: downloader.EventNovelDiscovered, NovelId: 2, Title: "b", Total: 1})
+	p.Handle(downloader.Event{Type: downloader.EventVolumeStarted, NovelId: 2, VolumeId: 10, Total: 4})
+	chapters(2, 10, 1)
+	if v := p.volumes[progressKey{2, 10}]; v.done != 1 || v.total != 4 {
+		t.Fatalf("unexpected progress of second novel: %v/%v", v.done, v.total)
+	}
+	if v := p.volumes[progressKey{1, 10}]; v.done != 3 || v.total != 3 {
+		t.Fatalf("first novel should not change: %v/%v", v.done, v.total)
+	}
+
+	p.Handle(downloader.Event{Type: downloader.EventVolumeFinished, NovelId: 1, VolumeId: 10})
+	p.Handle(downloader.Event{Type: downloader.EventVolumePacked, NovelId: 1, VolumeId: 10})
+	// 第一本小说的第二卷未开始，按平均章节数估算
+	summary := p.summary()
+	for _, want := range []string{"volumes 1/3", "chapters 4/10"} {
+		if !strings.Contains(summary, want) {
+			t.Fatalf("summary %q does not contain %q", summary, want)
+		}
+	}
+}
@@ -7,3 +7,14 @@ import (
 var RootCmd = &cobra.Command{
 	Use: "bilinovel-downloader",
 }
+
+// Execute 执行命令并返回退出码。命令运行中的失败由命令按失败的原因设置 exitStatus，
+// cobra 返回的错误只有参数、选项和未知命令等用法错误
+func Execute() int {
+	exitStatus = exitOK
+	err := RootCmd.Execute()
+	if err != nil {
+		return exitUsage
+	}
+	return exitStatus
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bilinovel-downloader/model"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// volumeChange update 模式下卷的变化，新下载的卷所有章节都记为新增
+type volumeChange struct {
+	volume *model.Volume
+	added  int
+	// retitled 目录中标题变化的章节，只有标题不变的修改无法从目录中发现
+	retitled int
+	removed  int
+}
+
+func (c volumeChange) empty() bool {
+	return c.added == 0 && c.retitled == 0 && c.removed == 0
+}
+
+// updateVolume 将目录中的章节列表与已下载的卷比较，只下载新增和标题变化的章节，有变化时重新保存并打包
+func (s *downloadSession) updateVolume(ctx context.Context, jsonPath string, latest *model.Volume) error {
+	cached, err := s.loadVolume(jsonPath)
+	if err != nil {
+		return err
+	}
+
+	cachedChapters := make(map[int]*model.Chapter, len(cached.Chapters))
+	for _, chapter := range cached.Chapters {
+		cachedChapters[chapter.Id] = chapter
+	}
+
+	change := volumeChange{volume: latest}
+	chapters := make([]*model.Chapter, len(latest.Chapters))
+	for i, chapter := range latest.Chapters {
+		if chapter.Id == 0 {
+			return fmt.Errorf("failed to get chapter id: %v", chapter.Url)
+		}
+		old, ok := cachedChapters[chapter.Id]
+		if ok && old.Content != nil && sameTitle(old.Title, chapter.Title) {
+			chapters[i] = old
+			delete(cachedChapters, chapter.Id)
+			continue
+		}
+		if ok {
+			change.retitled++
+			delete(cachedChapters, chapter.Id)
+			// 标题变化说明章节被修改过，缓存的内容已经过期
+			if s.cache != nil {
+				err = s.cache.Remove(s.target.site.Name, latest.NovelId, latest.Id, chapter.Id)
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			change.added++
+		}
+		chapters[i], err = s.downloader.GetChapter(ctx, latest.NovelId, latest.Id, chapter.Id)
+		if err != nil {
+			return fmt.Errorf("failed to get chapter: %w", err)
+		}
+	}
+	change.removed = len(cachedChapters)
+	s.recordChange(change)
+
+	if change.empty() {
+		slog.Debug("Volume is up to date", slog.Int("volumeId", latest.Id))
+		return nil
+	}
+
+	// 目录中的元数据可能也有更新，以最新的为准
+	latest.Chapters = chapters
+	if latest.CoverImage == "" {
+		latest.CoverImage = cached.CoverImage
+	}
+	return s.saveVolume(jsonPath, latest)
+}
+
+// recordChange 记录卷的变化，重试时同一卷的变化会合并
+func (s *downloadSession) recordChange(change volumeChange) {
+	for i, c := range s.changes {
+		if c.volume.Id == change.volume.Id {
+			s.changes[i].added += change.added
+			s.changes[i].retitled += change.retitled
+			s.changes[i].removed += change.removed
+			return
+		}
+	}
+	s.changes = append(s.changes, change)
+}
+
+// sameTitle 章节页面与目录中的标题可能有全角半角和空白的差别，规范化后相同才视为同一标题
+func sameTitle(cached string, catalog string) bool {
+	return normalizeTitle(cached) == normalizeTitle(catalog)
+}
+
+// normalizeTitle 将全角字母、数字、符号和空格转换为半角，并合并连续的空白
+func normalizeTitle(title string) string {
+	title = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\u3000':
+			return ' '
+		case r >= '\uff01' && r <= '\uff5e':
+			return r - 0xfee0
+		}
+		return r
+	}, title)
+	return strings.Join(strings.Fields(title), " ")
+}
+
+func printUpdateSummary(out io.Writer, changes []volumeChange) {
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "No volume updated")
+		return
+	}
+	for _, change := range changes {
+		status := "up to date"
+		if !change.empty() {
+			status = fmt.Sprintf("+%d new, ~%d retitled, -%d removed", change.added, change.retitled, change.removed)
+		}
+		fmt.Fprintf(out, "%v (%v): %v\n", change.volume.Title, change.volume.Id, status)
+	}
+}
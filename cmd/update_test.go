@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bilinovel-downloader/downloader"
+	"bilinovel-downloader/imagestore"
+	"bilinovel-downloader/model"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSameTitle(t *testing.T) {
+	cases := []struct {
+		cached  string
+		catalog string
+		want    bool
+	}{
+		{"第1章 开始", "第1章 开始", true},
+		{" 第1章 开始 ", "第1章 开始", true},
+		{"第１章　开始", "第1章 开始", true},
+		{"第1章  开始", "第1章 开始", true},
+		{"Ｃｈａｐｔｅｒ　１", "Chapter 1", true},
+		{"", "", true},
+		// 包含关系不视为相同
+		{"第1章", "第10章", false},
+		{"第1章 开始", "第1章 开始(修订)", false},
+		{"第1章 开始", "", false},
+	}
+	for _, c := range cases {
+		if got := sameTitle(c.cached, c.catalog); got != c.want {
+			t.Errorf("sameTitle(%q, %q) = %v, want %v", c.cached, c.catalog, got, c.want)
+		}
+	}
+}
+
+// fakeDownloader 按章节 ID 返回章节，并记录获取过的章节
+type fakeDownloader struct {
+	downloader.Downloader
+	fetched []int
+}
+
+func (d *fakeDownloader) GetChapter(ctx context.Context, novelId int, volumeId int, chapterId int) (*model.Chapter, error) {
+	d.fetched = append(d.fetched, chapterId)
+	return testChapter(chapterId, "new"), nil
+}
+
+func (d *fakeDownloader) GetStyleCSS() string { return "" }
+
+func testChapter(id int, title string) *model.Chapter {
+	return &model.Chapter{Id: id, NovelId: 1, VolumeId: 2, Title: title, Content: &model.ChaperContent{Html: "<p>" + title + "</p>"}}
+}
+
+func TestUpdateVolume(t *testing.T) {
+	outputType := downloadArgs.outputType
+	downloadArgs.outputType = ""
+	defer func() { downloadArgs.outputType = outputType }()
+
+	cached := []*model.Chapter{testChapter(1, "第1章"), testChapter(2, "第2章"), testChapter(3, "第3章")}
+	cases := []struct {
+		name    string
+		catalog []*model.Chapter
+		want    volumeChange
+		fetched []int
+	}{
+		{"up to date", []*model.Chapter{testChapter(1, "第１章"), testChapter(2, "第2章 "), testChapter(3, "第3章")}, volumeChange{}, nil},
+		{"added", []*model.Chapter{testChapter(1, "第1章"), testChapter(2, "第2章"), testChapter(3, "第3章"), testChapter(4, "第4章")}, volumeChange{added: 1}, []int{4}},
+		{"retitled", []*model.Chapter{testChapter(1, "第1章"), testChapter(2, "第2章(修订)"), testChapter(3, "第3章")}, volumeChange{retitled: 1}, []int{2}},
+		{"removed", []*model.Chapter{testChapter(1, "第1章"), testChapter(3, "第3章")}, volumeChange{removed: 1}, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			jsonPath := filepath.Join(t.TempDir(), "volume.json")
+			data, err := json.Marshal(&model.Volume{Id: 2, NovelId: 1, Chapters: cached})
+			if err != nil {
+				t.Fatalf("failed to encode volume: %v", err)
+			}
+			err = os.WriteFile(jsonPath, data, 0644)
+			if err != nil {
+				t.Fatalf("failed to write volume: %v", err)
+			}
+
+			dl := &fakeDownloader{}
+			ds := &downloadSession{
+				downloader: dl,
+				target:     &downloadTarget{site: &downloader.Site{Name: "bilinovel"}, novelId: 1},
+				images:     imagestore.New(t.TempDir()),
+			}
+			latest := &model.Volume{Id: 2, NovelId: 1, Chapters: c.catalog}
+			err = ds.updateVolume(context.Background(), jsonPath, latest)
+			if err != nil {
+				t.Fatalf("failed to update volume: %v", err)
+			}
+
+			if len(ds.changes) != 1 {
+				t.Fatalf("expected 1 change, got %v", len(ds.changes))
+			}
+			got := ds.changes[0]
+			if got.added != c.want.added || got.retitled != c.want.retitled || got.removed != c.want.removed {
+				t.Fatalf("unexpected change: %+v", got)
+			}
+			if !slices.Equal(dl.fetched, c.fetched) {
+				t.Fatalf("expected chapters %v to be fetched, got %v", c.fetched, dl.fetched)
+			}
+			// 只有变化时重新保存，保存后的章节顺序与目录一致
+			if !got.empty() {
+				updated, err := ds.loadVolume(jsonPath)
+				if err != nil {
+					t.Fatalf("failed to load volume: %v", err)
+				}
+				for i, chapter := range updated.Chapters {
+					if chapter.Id != c.catalog[i].Id {
+						t.Fatalf("unexpected chapter order: %v", updated.Chapters)
+					}
+				}
+			}
+		})
+	}
+}
@@ -1,22 +1,30 @@
 package bilinovel
 
 import (
+	"bilinovel-downloader/downloader"
+	"bilinovel-downloader/fonts"
+	"bilinovel-downloader/imagestore"
 	"bilinovel-downloader/model"
+	"bilinovel-downloader/session"
 	"bilinovel-downloader/utils"
 	"bytes"
-	"crypto/sha256"
+	"context"
 	_ "embed"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
 	"net/http"
+	"net/url"
 	"os"
-	"path"
 	"path/filepath"
 	"regexp"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	mapper "git.nite07.com/nite/font-mapper"
 	"github.com/PuerkitoBio/goquery"
@@ -26,40 +34,158 @@ import (
 //go:embed read.ttf
 var readTTF []byte
 
-//go:embed "MI LANTING.ttf"
-var miLantingTTF []byte
+// Renderer 章节页面的处理方式，用于还原段落顺序并移除诱饵段落
+type Renderer string
+
+const (
+	// RendererPlaywright 在 Chromium 中执行页面脚本
+	RendererPlaywright Renderer = "playwright"
+	// RendererNative 在 Go 中复刻页面脚本的算法，不需要浏览器
+	RendererNative Renderer = "native"
+	// RendererJS 在内嵌的 JS 引擎中执行页面脚本，不需要浏览器
+	RendererJS Renderer = "js"
+)
+
+// DefaultRenderTimeout 浏览器导航、等待元素和 JS 引擎执行脚本的默认超时时间
+const DefaultRenderTimeout = 10 * time.Second
 
 type Bilinovel struct {
+	site        *SiteDefinition
 	fontMapper  *mapper.GlyphOutlineMapper
 	textOnly    bool
 	restyClient *utils.RestyClient
+	renderer    Renderer
+	debug       bool
+
+	renderTimeout time.Duration
+	progress      downloader.ProgressFunc
+
+	// chapterlog.js 提取结果缓存
+	chapterlogCache map[string]*chapterlogResult
+	chapterlogMu    sync.Mutex
+
+	// 主机覆盖和代理，浏览器使用相同的配置
+	hosts       map[string]string
+	proxy       string
+	hostProxies map[string]string
+	// 导入的登录会话
+	cookies []*http.Cookie
+
+	// 页面 @font-face 声明的混淆字体，按字体地址缓存映射，字体文件缓存在 fontDir
+	fontMappers map[string]*mapper.GlyphOutlineMapper
+	fontMu      sync.Mutex
+	fontDir     string
 
 	// 浏览器实例复用
+	browserMu      sync.Mutex
+	pw             *playwright.Playwright
 	browser        playwright.Browser
 	browserContext playwright.BrowserContext
-	pages          map[string]playwright.Page
+	pagePool       *pagePool
 	concurrency    int
 	concurrentChan chan any
 
+	chapterConcurrency int
+
+	// 章节缓存，为空时不缓存
+	cache *downloader.ChapterCache
+	// 图片库
+	images *imagestore.Store
+
 	logger *slog.Logger
 }
 
 type BilinovelNewOption struct {
+	// Site 站点定义，为空时使用内置的 bilinovel 站点定义
+	Site        *SiteDefinition
 	Concurrency int
-	Debug       bool
+	// ChapterConcurrency 每卷同时下载的章节数，为 0 时逐章下载
+	ChapterConcurrency int
+	// MaxPages 同时打开的浏览器标签页上限，为 0 时与 ChapterConcurrency 相同
+	MaxPages int
+	Debug    bool
+	Renderer Renderer
+	// Timeout 单次网络请求的超时时间，为 0 时不超时
+	Timeout time.Duration
+	// RenderTimeout 浏览器导航、等待元素和 JS 引擎执行脚本的超时时间，为 0 时使用 DefaultRenderTimeout
+	RenderTimeout time.Duration
+	// CacheDir 章节缓存目录，为空时不缓存，已缓存的章节不会重新下载
+	CacheDir string
+	// ImageDir 图片库目录，为空时使用临时目录，已保存的图片不会重新下载
+	ImageDir string
+	// Progress 进度回调，设置后不再输出 Info 级别的日志
+	Progress downloader.ProgressFunc
+	// RateLimit 每个主机默认的请求速率限制，站点定义和 HostRateLimits 中的配置优先
+	RateLimit utils.RateLimit
+	// HostRateLimits 单独配置的主机的请求速率限制
+	HostRateLimits map[string]utils.RateLimit
+	// Hosts 主机覆盖，优先于站点定义中的配置，同时通过 --host-resolver-rules 传给浏览器
+	Hosts map[string]string
+	// Resolver 没有主机覆盖的主机使用的解析器，为空时使用系统解析，浏览器仍然使用系统解析
+	Resolver utils.Resolver
+	// Proxy 默认代理，为空时请求使用环境变量中的代理，浏览器不使用代理
+	Proxy string
+	// HostProxies 主机单独使用的代理，浏览器只支持直接连接的例外
+	HostProxies map[string]string
+	// Cookies 导入的登录会话，请求和浏览器共用
+	Cookies []*http.Cookie
 }
 
 func New(option BilinovelNewOption) (*Bilinovel, error) {
-	fontMapper, err := mapper.NewGlyphOutlineMapper(readTTF, miLantingTTF)
+	fontMapper, err := mapper.NewGlyphOutlineMapper(readTTF, fonts.MiLanting)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create font mapper: %v", err)
 	}
+	site := option.Site
+	if site == nil {
+		site = DefaultSite()
+	}
+
 	restyClient := utils.NewRestyClient(50)
+	restyClient.SetTimeout(option.Timeout)
+	restyClient.SetRateLimit("", option.RateLimit)
+	if site.RateLimit != nil {
+		restyClient.SetRateLimit(site.host(), *site.RateLimit)
+	}
+	for host, limit := range option.HostRateLimits {
+		restyClient.SetRateLimit(host, limit)
+	}
+	hosts := make(map[string]string)
+	maps.Copy(hosts, site.Hosts)
+	maps.Copy(hosts, option.Hosts)
+	restyClient.SetHosts(hosts)
+	restyClient.SetResolver(option.Resolver)
+	if option.Proxy != "" {
+		err = restyClient.SetProxy("", option.Proxy)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for host, proxy := range option.HostProxies {
+		err = restyClient.SetProxy(host, proxy)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(hosts) > 0 && (option.Proxy != "" || len(option.HostProxies) > 0) {
+		// 通过代理的请求由代理服务器解析主机名，主机覆盖只对直接连接的请求和代理服务器本身的地址有效
+		slog.Warn("Host overrides are ignored for requests sent through a proxy", slog.Any("hosts", slices.Sorted(maps.Keys(hosts))))
+	}
+	if len(option.Cookies) > 0 {
+		jar, err := session.NewJar(option.Cookies)
+		if err != nil {
+			return nil, err
+		}
+		restyClient.SetCookieJar(jar)
+	}
 
 	var logLevel slog.Level
-	if option.Debug {
+	switch {
+	case option.Debug:
 		logLevel = slog.LevelDebug
-	} else {
+	case option.Progress != nil:
+		logLevel = slog.LevelWarn
+	default:
 		logLevel = slog.LevelInfo
 	}
 
@@ -67,20 +193,67 @@ func New(option BilinovelNewOption) (*Bilinovel, error) {
 		Level: logLevel,
 	}
 
+	renderer := option.Renderer
+	if renderer == "" {
+		renderer = RendererPlaywright
+	}
+
+	renderTimeout := option.RenderTimeout
+	if renderTimeout <= 0 {
+		renderTimeout = DefaultRenderTimeout
+	}
+
+	chapterConcurrency := max(option.ChapterConcurrency, 1)
+	maxPages := option.MaxPages
+	if maxPages <= 0 {
+		maxPages = chapterConcurrency
+	}
+
 	b := &Bilinovel{
-		fontMapper:     fontMapper,
-		textOnly:       false,
-		restyClient:    restyClient,
-		pages:          make(map[string]playwright.Page),
-		concurrency:    option.Concurrency,
-		concurrentChan: make(chan any, option.Concurrency),
-		logger:         slog.New(slog.NewTextHandler(os.Stdout, handlerOptions)),
+		site:            site,
+		hosts:           hosts,
+		proxy:           option.Proxy,
+		hostProxies:     option.HostProxies,
+		cookies:         option.Cookies,
+		fontMapper:      fontMapper,
+		textOnly:        false,
+		restyClient:     restyClient,
+		renderer:        renderer,
+		debug:           option.Debug,
+		renderTimeout:   renderTimeout,
+		progress:        option.Progress,
+		chapterlogCache: make(map[string]*chapterlogResult),
+		fontMappers:     make(map[string]*mapper.GlyphOutlineMapper),
+		concurrency:     option.Concurrency,
+		concurrentChan:  make(chan any, option.Concurrency),
+		logger:          slog.New(slog.NewTextHandler(os.Stdout, handlerOptions)),
+
+		chapterConcurrency: chapterConcurrency,
+	}
+	b.pagePool = newPagePool(maxPages, b.newPage)
+	if option.CacheDir != "" {
+		b.cache = downloader.NewChapterCache(option.CacheDir)
+	}
+	b.fontDir = filepath.Join(os.TempDir(), "bilinovel-downloader", "fonts")
+	if option.CacheDir != "" {
+		b.fontDir = filepath.Join(option.CacheDir, "fonts")
 	}
+	imageDir := option.ImageDir
+	if imageDir == "" {
+		imageDir = filepath.Join(os.TempDir(), "bilinovel-downloader", "images")
+	}
+	b.images = imagestore.New(imageDir)
 
-	// 初始化浏览器实例
-	err = b.initBrowser(option.Debug)
-	if err != nil {
-		return nil, fmt.Errorf("failed to init browser: %v", err)
+	switch renderer {
+	case RendererPlaywright:
+		// 初始化浏览器实例
+		err = b.initBrowser(option.Debug)
+		if err != nil {
+			return nil, &downloader.BrowserError{Op: "launch", Err: err}
+		}
+	case RendererNative, RendererJS:
+	default:
+		return nil, fmt.Errorf("unknown renderer: %v", renderer)
 	}
 
 	return b, nil
@@ -100,11 +273,20 @@ func (b *Bilinovel) initBrowser(debug bool) error {
 	if err != nil {
 		return fmt.Errorf("could not start playwright: %w", err)
 	}
+	b.pw = pw
 
-	b.browser, err = pw.Chromium.Launch(playwright.BrowserTypeLaunchOptions{
+	launchOptions := playwright.BrowserTypeLaunchOptions{
 		Headless: playwright.Bool(!debug),
 		Devtools: playwright.Bool(debug),
-	})
+	}
+	if rules := utils.HostResolverRules(b.hosts); rules != "" {
+		launchOptions.Args = append(launchOptions.Args, "--host-resolver-rules="+rules)
+	}
+	launchOptions.Proxy, err = b.browserProxy()
+	if err != nil {
+		return err
+	}
+	b.browser, err = pw.Chromium.Launch(launchOptions)
 	if err != nil {
 		return fmt.Errorf("could not launch browser: %w", err)
 	}
@@ -113,13 +295,95 @@ func (b *Bilinovel) initBrowser(debug bool) error {
 	if err != nil {
 		return fmt.Errorf("could not create browser context: %w", err)
 	}
+	if len(b.cookies) > 0 {
+		err = b.browserContext.AddCookies(browserCookies(b.cookies))
+		if err != nil {
+			return fmt.Errorf("could not add cookies to browser context: %w", err)
+		}
+	}
 
 	b.logger.Info("Browser initialized successfully")
 	return nil
 }
 
-// Close 清理资源
+// browserProxy 将代理配置转换为浏览器的代理，Chromium 只支持一个代理，
+// 直接连接的主机加入例外列表，使用其他代理的主机仍然使用默认代理
+func (b *Bilinovel) browserProxy() (*playwright.Proxy, error) {
+	if b.proxy == "" {
+		if len(b.hostProxies) > 0 {
+			b.logger.Warn("Per-host proxies are not supported by the browser without a default proxy")
+		}
+		return nil, nil
+	}
+	u, err := utils.ParseProxy(b.proxy)
+	if err != nil {
+		return nil, err
+	}
+	// Chromium 不支持 socks5h，socks5 代理本身就由代理服务器解析主机名
+	scheme := u.Scheme
+	if scheme == "socks5h" {
+		scheme = "socks5"
+	}
+	proxy := &playwright.Proxy{Server: (&url.URL{Scheme: scheme, Host: u.Host}).String()}
+	if u.User != nil {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			b.logger.Warn("The browser only supports authentication of http proxies", slog.String("scheme", u.Scheme))
+		}
+		proxy.Username = playwright.String(u.User.Username())
+		if password, ok := u.User.Password(); ok {
+			proxy.Password = playwright.String(password)
+		}
+	}
+	bypass := make([]string, 0)
+	for host, hostProxy := range b.hostProxies {
+		if hostProxy == utils.ProxyDirect {
+			bypass = append(bypass, host)
+		} else if hostProxy != b.proxy {
+			b.logger.Warn("Per-host proxy is not supported by the browser, using the default proxy", slog.String("host", host))
+		}
+	}
+	if len(bypass) > 0 {
+		slices.Sort(bypass)
+		proxy.Bypass = playwright.String(strings.Join(bypass, ","))
+	}
+	return proxy, nil
+}
+
+// newPage 在复用的浏览器上下文中打开新的标签页
+func (b *Bilinovel) newPage() (playwright.Page, error) {
+	b.browserMu.Lock()
+	defer b.browserMu.Unlock()
+	if b.browserContext == nil {
+		return nil, fmt.Errorf("browser is not initialized")
+	}
+	return b.browserContext.NewPage()
+}
+
+// ensureBrowser 在 native 模式需要回退到 playwright 时按需安装并初始化浏览器
+func (b *Bilinovel) ensureBrowser() error {
+	b.browserMu.Lock()
+	defer b.browserMu.Unlock()
+	if b.browserContext != nil {
+		return nil
+	}
+
+	b.logger.Info("Installing playwright")
+	err := playwright.Install(&playwright.RunOptions{
+		Browsers: []string{"chromium"},
+		Stdout:   io.Discard,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to install playwright: %w", err)
+	}
+	return b.initBrowser(b.debug)
+}
+
+// Close 清理资源，关闭浏览器并停止 playwright 驱动进程
 func (b *Bilinovel) Close() error {
+	b.pagePool.Close()
+
+	b.browserMu.Lock()
+	defer b.browserMu.Unlock()
 	if b.browser != nil {
 		if err := b.browser.Close(); err != nil {
 			b.logger.Error("could not close browser", slog.Any("error", err))
@@ -127,6 +391,12 @@ func (b *Bilinovel) Close() error {
 		b.browser = nil
 		b.browserContext = nil
 	}
+	if b.pw != nil {
+		if err := b.pw.Stop(); err != nil {
+			b.logger.Error("could not stop playwright", slog.Any("error", err))
+		}
+		b.pw = nil
+	}
 	return nil
 }
 
@@ -137,164 +407,251 @@ func (b *Bilinovel) GetStyleCSS() string {
 	return string(styleCSS)
 }
 
-func (b *Bilinovel) GetNovel(novelId int, skipChapterContent bool, skipVolumes []int) (*model.Novel, error) {
+func (b *Bilinovel) GetNovel(ctx context.Context, novelId int, skipChapterContent bool, skipVolumes []int) (*model.Novel, error) {
 	b.logger.Info("Getting novel", slog.Int("novelId", novelId))
 
-	novelUrl := fmt.Sprintf("https://www.bilinovel.com/novel/%v.html", novelId)
-	resp, err := b.restyClient.R().Get(novelUrl)
+	novelUrl := b.site.url(b.site.Urls.Novel, novelId, 0, 0, 0)
+	resp, err := b.restyClient.R(ctx).Get(novelUrl)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get novel info: %w", err)
 	}
-	if resp.StatusCode() != http.StatusOK {
-		return nil, fmt.Errorf("failed to get novel info: %v", resp.Status())
+	err = checkResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get novel info: %w", err)
 	}
 
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body()))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse html: %v", err)
+		return nil, &downloader.ParseError{Url: novelUrl, Reason: fmt.Sprintf("failed to parse html: %v", err)}
 	}
 
 	novel := &model.Novel{}
 
-	novel.Title = strings.TrimSpace(doc.Find(".book-title").First().Text())
-	novel.Description = strings.TrimSpace(doc.Find(".book-summary>content").First().Text())
+	novel.Title = strings.TrimSpace(doc.Find(b.site.Selectors.Title).First().Text())
+	novel.Description = b.findText(doc, b.site.Selectors.Description)
 	novel.Id = novelId
-
-	doc.Find(".authorname>a").Each(func(i int, s *goquery.Selection) {
-		novel.Authors = append(novel.Authors, strings.TrimSpace(s.Text()))
-	})
-	doc.Find(".illname>a").Each(func(i int, s *goquery.Selection) {
-		novel.Authors = append(novel.Authors, strings.TrimSpace(s.Text()))
-	})
-
-	volumes, err := b.getAllVolumes(novelId, skipChapterContent, skipVolumes)
+	novel.Authors = b.findAuthors(doc)
+
+	volumes, err := b.getAllVolumes(ctx, novelId, skipChapterContent, skipVolumes)
+	var partial *downloader.PartialError
+	if errors.As(err, &partial) {
+		// 部分卷失败时仍然返回获取成功的卷
+		novel.Volumes = volumes
+		return novel, partial
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get novel volumes: %v", err)
+		return nil, fmt.Errorf("failed to get novel volumes: %w", err)
 	}
 	novel.Volumes = volumes
 
 	return novel, nil
 }
 
-func (b *Bilinovel) GetVolume(novelId int, volumeId int, skipChapterContent bool) (*model.Volume, error) {
+func (b *Bilinovel) GetVolume(ctx context.Context, novelId int, volumeId int, skipChapterContent bool, chapters downloader.ChapterFilter) (*model.Volume, error) {
 	b.logger.Info("Getting volume of novel", slog.Int("volumeId", volumeId), slog.Int("novelId", novelId))
 
-	novelUrl := fmt.Sprintf("https://www.bilinovel.com/novel/%v/catalog", novelId)
-	resp, err := b.restyClient.R().Get(novelUrl)
+	novelUrl := b.site.url(b.site.Urls.Catalog, novelId, 0, 0, 0)
+	resp, err := b.restyClient.R(ctx).Get(novelUrl)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get novel info: %w", err)
 	}
-	if resp.StatusCode() != http.StatusOK {
-		return nil, fmt.Errorf("failed to get novel info: %v", resp.Status())
+	err = checkResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get novel info: %w", err)
 	}
 
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body()))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse html: %v", err)
+		return nil, &downloader.ParseError{Url: novelUrl, Reason: fmt.Sprintf("failed to parse html: %v", err)}
 	}
 
 	seriesIdx := 0
-	doc.Find("a.volume-cover-img").Each(func(i int, s *goquery.Selection) {
-		if s.AttrOr("href", "") == fmt.Sprintf("/novel/%v/vol_%v.html", novelId, volumeId) {
+	volumePath := b.site.path(b.site.Urls.Volume, novelId, volumeId, 0, 0)
+	doc.Find(b.site.Selectors.VolumeLink).Each(func(i int, s *goquery.Selection) {
+		href := s.AttrOr("href", "")
+		if href == volumePath || href == b.site.absUrl(volumePath) {
 			seriesIdx = i + 1
 		}
 	})
 
-	novelTitle := strings.TrimSpace(doc.Find(".book-title").First().Text())
+	novelTitle := strings.TrimSpace(doc.Find(b.site.Selectors.Title).First().Text())
 
 	if seriesIdx == 0 {
-		return nil, fmt.Errorf("volume not found: %v", volumeId)
+		return nil, fmt.Errorf("volume %v: %w", volumeId, downloader.ErrNotFound)
 	}
 
-	volumeUrl := fmt.Sprintf("https://www.bilinovel.com/novel/%v/vol_%v.html", novelId, volumeId)
-	resp, err = b.restyClient.R().Get(volumeUrl)
+	volumeUrl := b.site.url(b.site.Urls.Volume, novelId, volumeId, 0, 0)
+	resp, err = b.restyClient.R(ctx).Get(volumeUrl)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get novel info: %v", err)
+		return nil, fmt.Errorf("failed to get novel info: %w", err)
 	}
-	if resp.StatusCode() != http.StatusOK {
-		return nil, fmt.Errorf("failed to get novel info: %v", resp.Status())
+	err = checkResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get novel info: %w", err)
 	}
 
 	doc, err = goquery.NewDocumentFromReader(bytes.NewReader(resp.Body()))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse html: %v", err)
+		return nil, &downloader.ParseError{Url: volumeUrl, Reason: fmt.Sprintf("failed to parse html: %v", err)}
 	}
 
 	volume := &model.Volume{}
 	volume.NovelId = novelId
 	volume.NovelTitle = novelTitle
+	volume.Language = b.site.Language
 	volume.Id = volumeId
 	volume.SeriesIdx = seriesIdx
-	volume.Title = strings.TrimSpace(doc.Find(".book-title").First().Text())
-	volume.Description = strings.TrimSpace(doc.Find(".book-summary>content").First().Text())
+	volume.Title = strings.TrimSpace(doc.Find(b.site.Selectors.Title).First().Text())
+	volume.Description = b.findText(doc, b.site.Selectors.Description)
 	volume.Url = volumeUrl
 	volume.Chapters = make([]*model.Chapter, 0)
-	volume.CoverUrl = doc.Find(".book-cover").First().AttrOr("src", "")
-	cover, err := b.getImg(volume.CoverUrl)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get cover: %v", err)
+	if b.site.Selectors.Cover != "" {
+		volume.CoverUrl = doc.Find(b.site.Selectors.Cover).First().AttrOr("src", "")
+	}
+	if volume.CoverUrl != "" {
+		volume.CoverImage, err = b.storeImg(ctx, volume.CoverUrl)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			// 封面获取失败不影响正文
+			b.logger.Warn("Failed to get cover", slog.String("url", volume.CoverUrl), slog.Any("error", err))
+		}
 	}
-	volume.Cover = cover
 
-	doc.Find(".authorname>a").Each(func(i int, s *goquery.Selection) {
-		volume.Authors = append(volume.Authors, strings.TrimSpace(s.Text()))
-	})
-	doc.Find(".illname>a").Each(func(i int, s *goquery.Selection) {
-		volume.Authors = append(volume.Authors, strings.TrimSpace(s.Text()))
-	})
-	doc.Find(".chapter-li.jsChapter").Each(func(i int, s *goquery.Selection) {
+	volume.Authors = b.findAuthors(doc)
+	doc.Find(b.site.Selectors.ChapterLink).Each(func(i int, s *goquery.Selection) {
 		volume.Chapters = append(volume.Chapters, &model.Chapter{
-			Title: s.Find("a").Text(),
-			Url:   fmt.Sprintf("https://www.bilinovel.com%v", s.Find("a").AttrOr("href", "")),
+			Title: s.Text(),
+			Url:   b.site.absUrl(s.AttrOr("href", "")),
 		})
 	})
 
-	idRegexp := regexp.MustCompile(`/novel/(\d+)/(\d+).html`)
+	// 目录中的章节也带上 ID，便于和已下载的卷比较
+	idRegexp := b.site.templateRegexp(b.site.Urls.Chapter, 0, "{chapterId}")
+	chapterIds := make([]int, len(volume.Chapters))
+	for i, chapter := range volume.Chapters {
+		chapter.NovelId = novelId
+		chapter.VolumeId = volumeId
+		matches := idRegexp.FindStringSubmatch(chapter.Url)
+		if len(matches) == 0 {
+			continue
+		}
+		chapterId, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return nil, &downloader.ParseError{Url: chapter.Url, Reason: fmt.Sprintf("failed to convert chapter id: %v", err)}
+		}
+		chapter.Id = chapterId
+		chapterIds[i] = chapterId
+	}
+
+	if chapters != nil {
+		// 只保留选中的章节，序号按完整的目录计算
+		selected := make([]*model.Chapter, 0, len(volume.Chapters))
+		selectedIds := make([]int, 0, len(chapterIds))
+		for i, chapter := range volume.Chapters {
+			if chapters(i+1, chapter) {
+				selected = append(selected, chapter)
+				selectedIds = append(selectedIds, chapterIds[i])
+			}
+		}
+		volume.Chapters, chapterIds = selected, selectedIds
+	}
 
 	if !skipChapterContent {
-		for i := range volume.Chapters {
-			matches := idRegexp.FindStringSubmatch(volume.Chapters[i].Url)
-			if len(matches) > 0 {
-				chapterId, err := strconv.Atoi(matches[2])
-				if err != nil {
-					return nil, fmt.Errorf("failed to convert chapter id: %v", err)
-				}
-				chapter, err := b.GetChapter(novelId, volumeId, chapterId)
-				if err != nil {
-					return nil, fmt.Errorf("failed to get chapter: %v", err)
-				}
-				chapter.Id = chapterId
-				volume.Chapters[i] = chapter
-			} else {
-				return nil, fmt.Errorf("failed to get chapter id: %v", volume.Chapters[i].Url)
+		for _, chapter := range volume.Chapters {
+			if chapter.Id == 0 {
+				return nil, &downloader.ParseError{Url: chapter.Url, Reason: "failed to get chapter id"}
 			}
 		}
+		b.progress.Emit(downloader.Event{
+			Type:     downloader.EventVolumeStarted,
+			NovelId:  novelId,
+			VolumeId: volumeId,
+			Title:    volume.Title,
+			Total:    len(volume.Chapters),
+		})
+		err = b.getChapters(ctx, volume, chapterIds)
+		if err != nil {
+			return nil, err
+		}
+		b.progress.Emit(downloader.Event{
+			Type:     downloader.EventVolumeFinished,
+			NovelId:  novelId,
+			VolumeId: volumeId,
+			Title:    volume.Title,
+		})
 	}
 
 	return volume, nil
 }
 
-func (b *Bilinovel) getAllVolumes(novelId int, skipChapterContent bool, skipVolumes []int) ([]*model.Volume, error) {
+// getChapters 同时下载卷中的多个章节，结果按目录顺序写回 volume.Chapters，
+// 任意章节失败时取消其余章节并返回第一个错误
+func (b *Bilinovel) getChapters(ctx context.Context, volume *model.Volume, chapterIds []int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	sem := make(chan struct{}, b.chapterConcurrency)
+
+loop:
+	for i, chapterId := range chapterIds {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break loop
+		}
+		wg.Add(1)
+		go func(i int, chapterId int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			chapter, err := b.GetChapter(ctx, volume.NovelId, volume.Id, chapterId)
+			if err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("failed to get chapter: %w", err)
+					cancel()
+				})
+				return
+			}
+			chapter.Id = chapterId
+			// 每个 goroutine 只写自己的下标，保证章节顺序与目录一致
+			volume.Chapters[i] = chapter
+		}(i, chapterId)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+func (b *Bilinovel) getAllVolumes(ctx context.Context, novelId int, skipChapterContent bool, skipVolumes []int) ([]*model.Volume, error) {
 	b.logger.Info("Getting all volumes of novel", slog.Int("novelId", novelId))
 
-	catelogUrl := fmt.Sprintf("https://www.bilinovel.com/novel/%v/catalog", novelId)
-	resp, err := b.restyClient.R().Get(catelogUrl)
+	catelogUrl := b.site.url(b.site.Urls.Catalog, novelId, 0, 0, 0)
+	resp, err := b.restyClient.R(ctx).Get(catelogUrl)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get catelog: %v", err)
+		return nil, fmt.Errorf("failed to get catelog: %w", err)
 	}
-	if resp.StatusCode() != http.StatusOK {
-		return nil, fmt.Errorf("failed to get catelog: %v", resp.Status())
+	err = checkResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get catelog: %w", err)
 	}
 
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body()))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse html: %v", err)
+		return nil, &downloader.ParseError{Url: catelogUrl, Reason: fmt.Sprintf("failed to parse html: %v", err)}
 	}
 
-	volumeRegexp := regexp.MustCompile(fmt.Sprintf(`/novel/%v/vol_(\d+).html`, novelId))
+	volumeRegexp := b.site.templateRegexp(b.site.Urls.Volume, novelId, "{volumeId}")
 
 	volumeIds := make([]string, 0)
-	doc.Find("a.volume-cover-img").Each(func(i int, s *goquery.Selection) {
+	doc.Find(b.site.Selectors.VolumeLink).Each(func(i int, s *goquery.Selection) {
 		link := s.AttrOr("href", "")
 		matches := volumeRegexp.FindStringSubmatch(link)
 		if len(matches) > 0 {
@@ -302,13 +659,34 @@ func (b *Bilinovel) getAllVolumes(novelId int, skipChapterContent bool, skipVolu
 		}
 	})
 
+	total := 0
+	for _, volumeIdStr := range volumeIds {
+		volumeId, err := strconv.Atoi(volumeIdStr)
+		if err == nil && !slices.Contains(skipVolumes, volumeId) {
+			total++
+		}
+	}
+	b.progress.Emit(downloader.Event{
+		Type:    downloader.EventNovelDiscovered,
+		NovelId: novelId,
+		Title:   strings.TrimSpace(doc.Find(b.site.Selectors.Title).First().Text()),
+		Total:   total,
+	})
+
 	volumes := make([]*model.Volume, len(volumeIds))
+	failed := make([]*downloader.VolumeError, len(volumeIds))
 	var wg sync.WaitGroup
-	var mu sync.Mutex // 保护 volumes 写入的互斥锁
+	var mu sync.Mutex // 保护 volumes 和 failed 写入的互斥锁
 
+loop:
 	for i, volumeIdStr := range volumeIds {
+		// 获取一个并发槽，取消后不再启动新的任务
+		select {
+		case b.concurrentChan <- struct{}{}:
+		case <-ctx.Done():
+			break loop
+		}
 		wg.Add(1)
-		b.concurrentChan <- struct{}{} // 获取一个并发槽
 
 		go func(i int, volumeIdStr string) {
 			defer wg.Done()
@@ -322,19 +700,24 @@ func (b *Bilinovel) getAllVolumes(novelId int, skipChapterContent bool, skipVolu
 			if slices.Contains(skipVolumes, volumeId) {
 				return
 			}
-			volume, err := b.GetVolume(novelId, volumeId, skipChapterContent)
+			volume, err := b.GetVolume(ctx, novelId, volumeId, skipChapterContent, nil)
 			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
 				b.logger.Error("failed to get volume info", slog.Int("novelId", novelId), slog.Int("volumeId", volumeId), slog.Any("error", err))
+				b.progress.Emit(downloader.Event{
+					Type:     downloader.EventFailed,
+					NovelId:  novelId,
+					VolumeId: volumeId,
+					Err:      err,
+				})
+				mu.Lock()
+				failed[i] = &downloader.VolumeError{VolumeId: volumeId, SeriesIdx: i + 1, Err: err}
+				mu.Unlock()
 				return
 			}
-			volume.SeriesIdx = i
-
-			// 关闭浏览器标签页
-			pwPageKey := fmt.Sprintf("%v-%v", novelId, volumeId)
-			if pwPage, ok := b.pages[pwPageKey]; ok {
-				_ = pwPage.Close()
-				delete(b.pages, pwPageKey)
-			}
+			volume.SeriesIdx = i + 1
 
 			mu.Lock()
 			volumes[i] = volume
@@ -343,6 +726,9 @@ func (b *Bilinovel) getAllVolumes(novelId int, skipChapterContent bool, skipVolu
 	}
 
 	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// 过滤掉获取失败的 nil volume
 	filteredVolumes := make([]*model.Volume, 0, len(volumes))
@@ -352,10 +738,34 @@ func (b *Bilinovel) getAllVolumes(novelId int, skipChapterContent bool, skipVolu
 		}
 	}
 
+	partial := &downloader.PartialError{NovelId: novelId}
+	for _, volumeErr := range failed {
+		if volumeErr != nil {
+			partial.Volumes = append(partial.Volumes, volumeErr)
+		}
+	}
+	if len(partial.Volumes) > 0 {
+		return filteredVolumes, partial
+	}
+
 	return filteredVolumes, nil
 }
 
-func (b *Bilinovel) GetChapter(novelId int, volumeId int, chapterId int) (*model.Chapter, error) {
+func (b *Bilinovel) GetChapter(ctx context.Context, novelId int, volumeId int, chapterId int) (*model.Chapter, error) {
+	if b.cache != nil {
+		if chapter, ok := b.cache.Load(b.site.Name, novelId, volumeId, chapterId); ok && b.images.MigrateChapter(chapter) == nil {
+			b.logger.Info("Using cached chapter", slog.Int("chapterId", chapterId), slog.Int("novelId", novelId))
+			b.progress.Emit(downloader.Event{
+				Type:      downloader.EventChapterFinished,
+				NovelId:   novelId,
+				VolumeId:  volumeId,
+				ChapterId: chapterId,
+				Title:     chapter.Title,
+			})
+			return chapter, nil
+		}
+	}
+
 	b.logger.Info("Getting chapter of novel", slog.Int("chapterId", chapterId), slog.Int("novelId", novelId))
 
 	pageNum := 1
@@ -363,71 +773,136 @@ func (b *Bilinovel) GetChapter(novelId int, volumeId int, chapterId int) (*model
 		Id:       chapterId,
 		NovelId:  novelId,
 		VolumeId: volumeId,
-		Url:      fmt.Sprintf("https://www.bilinovel.com/novel/%v/%v.html", novelId, chapterId),
+		Url:      b.site.url(b.site.Urls.Chapter, novelId, volumeId, chapterId, 0),
 	}
-	for {
-		pwPageKey := fmt.Sprintf("%v-%v", novelId, volumeId)
-		if _, ok := b.pages[pwPageKey]; !ok {
-			pwPage, err := b.browserContext.NewPage()
-			if err != nil {
-				return nil, fmt.Errorf("failed to create browser page: %w", err)
-			}
-			b.pages[pwPageKey] = pwPage
+	// 章节的所有页使用同一个标签页，下载完成后归还给标签页池
+	var pwPage playwright.Page
+	if b.renderer == RendererPlaywright {
+		var err error
+		pwPage, err = b.pagePool.Get(ctx)
+		if err != nil {
+			return nil, err
 		}
-		hasNext, err := b.getChapterByPage(b.pages[pwPageKey], chapter, pageNum)
+		defer b.pagePool.Put(pwPage)
+	}
+	missingImages := 0
+	for {
+		hasNext, missing, err := b.getChapterByPage(ctx, pwPage, chapter, pageNum)
 		if err != nil {
+			if ctx.Err() == nil {
+				b.progress.Emit(downloader.Event{
+					Type:      downloader.EventFailed,
+					NovelId:   novelId,
+					VolumeId:  volumeId,
+					ChapterId: chapterId,
+					Page:      pageNum,
+					Err:       err,
+				})
+			}
 			return nil, fmt.Errorf("failed to download chapter: %w", err)
 		}
+		missingImages += missing
+		b.progress.Emit(downloader.Event{
+			Type:      downloader.EventChapterPageFetched,
+			NovelId:   novelId,
+			VolumeId:  volumeId,
+			ChapterId: chapterId,
+			Title:     chapter.Title,
+			Page:      pageNum,
+		})
 		if !hasNext {
 			break
 		}
 		pageNum++
 	}
+	b.progress.Emit(downloader.Event{
+		Type:      downloader.EventChapterFinished,
+		NovelId:   novelId,
+		VolumeId:  volumeId,
+		ChapterId: chapterId,
+		Title:     chapter.Title,
+		Page:      pageNum,
+	})
+
+	// 有图片下载失败时不缓存章节，下次运行时重新下载
+	if missingImages > 0 {
+		b.logger.Warn("Some images of chapter failed, chapter will not be cached", slog.Int("chapterId", chapterId), slog.Int("count", missingImages))
+	} else if b.cache != nil {
+		err := b.cache.Save(b.site.Name, chapter)
+		if err != nil {
+			b.logger.Warn("Failed to cache chapter", slog.Int("chapterId", chapterId), slog.Any("error", err))
+		}
+	}
 	return chapter, nil
 }
 
 var nextPageUrlRegexp = regexp.MustCompile(`url_next:\s?['"]([^'"]*?)['"]`)
 var cleanNextPageUrlRegexp = regexp.MustCompile(`(_\d+)?\.html$`)
 
-func (b *Bilinovel) getChapterByPage(pwPage playwright.Page, chapter *model.Chapter, pageNum int) (bool, error) {
+// getChapterByPage 下载章节的一页，返回是否有下一页和下载失败的图片数量
+func (b *Bilinovel) getChapterByPage(ctx context.Context, pwPage playwright.Page, chapter *model.Chapter, pageNum int) (bool, int, error) {
 	b.logger.Info("Getting chapter by page", slog.Int("chapter", chapter.Id), slog.Int("page", pageNum))
 
-	Url := strings.TrimSuffix(chapter.Url, ".html") + fmt.Sprintf("_%v.html", pageNum)
+	Url := b.site.url(b.site.Urls.ChapterPage, chapter.NovelId, chapter.VolumeId, chapter.Id, pageNum)
 
 	hasNext := false
 	headers := map[string]string{
 		"Accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
-		"Accept-Language": "zh-CN,zh;q=0.9,en-GB;q=0.8,en;q=0.7,zh-TW;q=0.6",
-		"Cookie":          "night=1;",
+		"Accept-Language": b.site.acceptLanguage(),
 	}
-	resp, err := b.restyClient.R().SetHeaders(headers).Get(Url)
+	// 导入的会话中的 cookie 由 cookie jar 添加
+	resp, err := b.restyClient.R(ctx).SetHeaders(headers).SetCookie(&http.Cookie{Name: "night", Value: "1"}).Get(Url)
 	if err != nil {
-		return false, fmt.Errorf("failed to get chapter: %w", err)
+		return false, 0, fmt.Errorf("failed to get chapter: %w", err)
 	}
-	if resp.StatusCode() != http.StatusOK {
-		return false, fmt.Errorf("failed to get chapter: %v", resp.Status())
+	err = checkResponse(resp)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to get chapter: %w", err)
+	}
+	err = b.checkLogin(resp.RawResponse.Request.URL.Path, resp.String())
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to get chapter %v: %w", chapter.Id, err)
 	}
 
-	if strings.Contains(resp.String(), `<a onclick="window.location.href = ReadParams.url_next;">下一頁</a>`) {
+	if b.site.NextPageMarker != "" && strings.Contains(resp.String(), b.site.NextPageMarker) {
 		hasNext = true
 	}
 
 	html := resp.Body()
 
 	// 解决乱序问题
-	resortedHtml, err := b.processContentWithPlaywright(pwPage, string(html))
+	var resortedHtml string
+	switch b.renderer {
+	case RendererNative:
+		params, paramsErr := b.getUnscrambleParams(ctx, Url, string(html))
+		switch {
+		case ctx.Err() != nil:
+			err = ctx.Err()
+		case paramsErr != nil:
+			resortedHtml, err = b.processContentWithFallbackBrowser(ctx, string(html))
+		default:
+			resortedHtml, err = b.processContentNative(string(html), chapter.Id, *params)
+		}
+	case RendererJS:
+		resortedHtml, err = b.processContentWithJS(ctx, Url, string(html))
+	default:
+		resortedHtml, err = b.processContentWithPlaywright(ctx, pwPage, string(html))
+		if err != nil && ctx.Err() == nil {
+			err = &downloader.BrowserError{Op: "render", Err: err}
+		}
+	}
 	if err != nil {
-		return false, fmt.Errorf("failed to process html: %w", err)
+		return false, 0, fmt.Errorf("failed to process html: %w", err)
 	}
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(resortedHtml))
 	if err != nil {
-		return false, fmt.Errorf("failed to parse html: %w", err)
+		return false, 0, &downloader.ParseError{Url: Url, Reason: fmt.Sprintf("failed to parse html: %v", err)}
 	}
 
 	// 判断章节是否有下一页
 	n := nextPageUrlRegexp.FindStringSubmatch(resortedHtml)
 	if len(n) != 2 {
-		return false, fmt.Errorf("failed to determine wether there is a next page")
+		return false, 0, &downloader.ParseError{Url: Url, Reason: "failed to determine whether there is a next page"}
 	}
 
 	s := cleanNextPageUrlRegexp.ReplaceAllString(n[1], "")
@@ -436,28 +911,22 @@ func (b *Bilinovel) getChapterByPage(pwPage playwright.Page, chapter *model.Chap
 	}
 
 	if pageNum == 1 {
-		chapter.Title = doc.Find("#atitle").Text()
+		chapter.Title = doc.Find(b.site.Selectors.ChapterTitle).Text()
+	}
+	content := doc.Find(b.site.Selectors.Content).First()
+	if strings.TrimSpace(content.Text()) == "" && vipChapterRegexp.MatchString(resortedHtml) {
+		return false, 0, fmt.Errorf("failed to get chapter %v: %w", chapter.Id, b.loginError())
+	}
+	for _, selector := range b.site.Cleanup {
+		content.Find(selector).Remove()
 	}
-	content := doc.Find("#acontent").First()
-	content.Find(".cgo").Remove()
-	content.Find("center").Remove()
-	content.Find(".google-auto-placed").Remove()
 
-	if strings.Contains(resortedHtml, `font-family: "read"`) {
-		html, err := content.Find("p").Last().Html()
-		if err != nil {
-			return false, fmt.Errorf("failed to get html: %v", err)
-		}
-		builder := strings.Builder{}
-		for _, r := range html {
-			_, newRune, ok := b.fontMapper.MappingRune(r)
-			if ok {
-				builder.WriteRune(newRune)
-			}
-		}
-		content.Find("p").Last().SetHtml(builder.String())
+	err = b.deobfuscate(ctx, doc, content, Url, resortedHtml)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to deobfuscate content: %w", err)
 	}
 
+	missingImages := 0
 	if b.textOnly {
 		content.Find("img").Remove()
 	} else {
@@ -470,22 +939,34 @@ func (b *Bilinovel) getChapterByPage(pwPage playwright.Page, chapter *model.Chap
 				}
 			}
 
-			imageHash := sha256.Sum256([]byte(imgUrl))
-			imageFilename := fmt.Sprintf("%x%s", string(imageHash[:]), path.Ext(imgUrl))
+			imageFilename := imagestore.Name(imgUrl)
 			s.SetAttr("src", imageFilename)
 			s.SetAttr("alt", imgUrl)
 			s.RemoveAttr("class")
-			img, err := b.getImg(imgUrl)
+			_, err := b.storeImg(ctx, imgUrl)
 			if err != nil {
+				missingImages++
+				b.progress.Emit(downloader.Event{
+					Type:      downloader.EventFailed,
+					NovelId:   chapter.NovelId,
+					VolumeId:  chapter.VolumeId,
+					ChapterId: chapter.Id,
+					Url:       imgUrl,
+					Err:       err,
+				})
 				return
 			}
+			b.progress.Emit(downloader.Event{
+				Type:      downloader.EventImageFetched,
+				NovelId:   chapter.NovelId,
+				VolumeId:  chapter.VolumeId,
+				ChapterId: chapter.Id,
+				Url:       imgUrl,
+			})
 			if chapter.Content == nil {
 				chapter.Content = &model.ChaperContent{}
 			}
-			if chapter.Content.Images == nil {
-				chapter.Content.Images = make(map[string][]byte)
-			}
-			chapter.Content.Images[imageFilename] = img
+			chapter.Content.AddImage(imageFilename)
 		})
 	}
 
@@ -504,7 +985,7 @@ func (b *Bilinovel) getChapterByPage(pwPage playwright.Page, chapter *model.Chap
 
 	htmlStr, err := content.Html()
 	if err != nil {
-		return false, fmt.Errorf("failed to get html: %v", err)
+		return false, 0, fmt.Errorf("failed to get html: %v", err)
 	}
 
 	if chapter.Content == nil {
@@ -512,21 +993,87 @@ func (b *Bilinovel) getChapterByPage(pwPage playwright.Page, chapter *model.Chap
 	}
 	chapter.Content.Html += strings.TrimSpace(htmlStr)
 
-	return hasNext, nil
+	return hasNext, missingImages, nil
+}
+
+// findText 返回选择器匹配的第一个元素的文本，选择器为空时返回空字符串
+func (b *Bilinovel) findText(doc *goquery.Document, selector string) string {
+	if selector == "" {
+		return ""
+	}
+	return strings.TrimSpace(doc.Find(selector).First().Text())
+}
+
+func (b *Bilinovel) findAuthors(doc *goquery.Document) []string {
+	var authors []string
+	for _, selector := range b.site.Selectors.Authors {
+		doc.Find(selector).Each(func(i int, s *goquery.Selection) {
+			authors = append(authors, strings.TrimSpace(s.Text()))
+		})
+	}
+	return authors
 }
 
-func (b *Bilinovel) getImg(url string) ([]byte, error) {
+func (b *Bilinovel) getImg(ctx context.Context, url string) ([]byte, error) {
 	b.logger.Info("Getting img", slog.String("url", url))
-	resp, err := b.restyClient.R().SetHeader("Referer", "https://www.bilinovel.com").Get(url)
+	resp, err := b.restyClient.R(ctx).SetHeader("Referer", b.site.BaseUrl).Get(url)
 	if err != nil {
 		return nil, err
 	}
+	err = checkResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get img: %w", err)
+	}
 
 	return resp.Body(), nil
 }
 
+// storeImg 将图片保存到图片库并返回文件名，图片库中已有的图片不会重新下载
+func (b *Bilinovel) storeImg(ctx context.Context, url string) (string, error) {
+	name := imagestore.Name(url)
+	if b.images.Has(name) {
+		return name, nil
+	}
+	img, err := b.getImg(ctx, url)
+	if err != nil {
+		return "", err
+	}
+	err = b.images.Put(name, img)
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// processContentWithFallbackBrowser 无法提取重排参数时，使用临时标签页交给浏览器处理
+func (b *Bilinovel) processContentWithFallbackBrowser(ctx context.Context, htmlContent string) (string, error) {
+	err := b.ensureBrowser()
+	if err != nil {
+		return "", &downloader.BrowserError{Op: "launch fallback browser", Err: err}
+	}
+	pwPage, err := b.pagePool.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer b.pagePool.Put(pwPage)
+	result, err := b.processContentWithPlaywright(ctx, pwPage, htmlContent)
+	if err != nil && ctx.Err() == nil {
+		return "", &downloader.BrowserError{Op: "render", Err: err}
+	}
+	return result, err
+}
+
+// playwrightTimeout 返回 playwright 操作的超时时间（毫秒），不超过 ctx 的截止时间
+func (b *Bilinovel) playwrightTimeout(ctx context.Context) *float64 {
+	timeout := b.renderTimeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = max(time.Until(deadline), time.Millisecond)
+	}
+	return playwright.Float(float64(timeout.Milliseconds()))
+}
+
 // processContentWithPlaywright 使用复用的浏览器实例处理内容
-func (b *Bilinovel) processContentWithPlaywright(page playwright.Page, htmlContent string) (string, error) {
+func (b *Bilinovel) processContentWithPlaywright(ctx context.Context, page playwright.Page, htmlContent string) (string, error) {
 	// 替换 window.location.replace，防止页面跳转
 	htmlContent = strings.ReplaceAll(htmlContent, "window.location.replace", "console.log")
 
@@ -576,6 +1123,13 @@ func (b *Bilinovel) processContentWithPlaywright(page playwright.Page, htmlConte
 	// 	return "", fmt.Errorf("failed to intercept requests: %w", err)
 	// }
 
+	// playwright-go 不支持 context，取消时关闭标签页让正在进行的导航和等待立即返回
+	stop := context.AfterFunc(ctx, func() {
+		_ = page.Close()
+	})
+	defer stop()
+	timeout := b.playwrightTimeout(ctx)
+
 	_, err = page.ExpectResponse(func(url string) bool {
 		return strings.Contains(url, "chapterlog.js")
 	}, func() error {
@@ -585,26 +1139,32 @@ func (b *Bilinovel) processContentWithPlaywright(page playwright.Page, htmlConte
 		}
 		return nil
 	}, playwright.PageExpectResponseOptions{
-		Timeout: playwright.Float(10000),
+		Timeout: timeout,
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to wait for network request finish")
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("failed to wait for network request finish: %w", err)
 	}
 
-	err = page.Locator("#acontent").WaitFor(playwright.LocatorWaitForOptions{
+	err = page.Locator(b.site.Selectors.Content).First().WaitFor(playwright.LocatorWaitForOptions{
 		State:   playwright.WaitForSelectorStateVisible,
-		Timeout: playwright.Float(10000),
+		Timeout: timeout,
 	})
 	if err != nil {
-		return "", fmt.Errorf("could not wait for #acontent: %w", err)
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("could not wait for %v: %w", b.site.Selectors.Content, err)
 	}
 
-	// 遍历所有 #acontent 的子元素, 通过 window.getComputedStyle().display 检测是否是 none, 如果是 none 则从页面删除这个元素
+	// 遍历所有正文的子元素, 通过 window.getComputedStyle().display 检测是否是 none, 如果是 none 则从页面删除这个元素
 	result, err := page.Evaluate(`
-		(function() {
-			const acontent = document.getElementById('acontent');
+		(function(selector) {
+			const acontent = document.querySelector(selector);
 			if (!acontent) {
-				return 'acontent element not found';
+				return 'content element not found';
 			}
 			
 			let removedCount = 0;
@@ -622,8 +1182,8 @@ func (b *Bilinovel) processContentWithPlaywright(page playwright.Page, htmlConte
 			}
 			
 			return 'Removed ' + removedCount + ' hidden elements';
-		})()
-	`)
+		})
+	`, b.site.Selectors.Content)
 
 	if err != nil {
 		return "", fmt.Errorf("failed to remove hidden elements: %w", err)
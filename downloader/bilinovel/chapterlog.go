@@ -0,0 +1,242 @@
+package bilinovel
+
+import (
+	"bilinovel-downloader/downloader"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// UnscrambleParams chapterlog.js 中段落重排算法使用的参数
+type UnscrambleParams struct {
+	// 种子 = chapterId*SeedMultiplier + SeedIncrement
+	SeedMultiplier int64
+	SeedIncrement  int64
+	// 线性同余生成器 ms = (ms*Multiplier + Increment) % Modulus
+	Multiplier int64
+	Increment  int64
+	Modulus    int64
+	// 段落数不超过 Threshold 时不重排，且前 Threshold 个段落顺序不变
+	Threshold int
+}
+
+// DefaultUnscrambleParams 分析 chapterlog.js?v1006c1 得到的参数
+var DefaultUnscrambleParams = UnscrambleParams{
+	SeedMultiplier: 127,
+	SeedIncrement:  235,
+	Multiplier:     9302,
+	Increment:      49397,
+	Modulus:        233280,
+	Threshold:      20,
+}
+
+const jsNumber = `(0[xX][0-9a-fA-F]+|\d+)`
+
+var (
+	// (ms * 9302 + 49397) % 233280
+	lcgRegexp = regexp.MustCompile(`\*\s*` + jsNumber + `\s*\+\s*` + jsNumber + `\s*\)\s*%\s*` + jsNumber)
+	// chapterid * 127 + 235
+	seedRegexp = regexp.MustCompile(`\*\s*` + jsNumber + `\s*\+\s*` + jsNumber)
+	// j <= 20 / j > 20
+	thresholdRegexp = regexp.MustCompile(`[<>]=?\s*` + jsNumber)
+	// i + 20 / j - 20
+	offsetRegexp = regexp.MustCompile(`[-+]\s*` + jsNumber)
+
+	chapterlogScriptRegexp = regexp.MustCompile(`<script[^>]+src=["']([^"']*chapterlog\.js[^"']*)["']`)
+)
+
+func parseJSNumber(s string) (int64, error) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return strconv.ParseInt(s[2:], 16, 64)
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// ExtractUnscrambleParams 从 chapterlog.js 的源码中提取段落重排算法的参数，
+// 无法唯一确定任何一个参数时都会返回错误，而不是猜测一个值
+func ExtractUnscrambleParams(script string) (*UnscrambleParams, error) {
+	params := &UnscrambleParams{}
+
+	lcgMatches := lcgRegexp.FindAllStringSubmatchIndex(script, -1)
+	if len(lcgMatches) != 1 {
+		return nil, fmt.Errorf("expected exactly 1 lcg expression, found %d", len(lcgMatches))
+	}
+	lcg := lcgMatches[0]
+	values := make([]int64, 3)
+	for i := range values {
+		v, err := parseJSNumber(script[lcg[2+i*2]:lcg[3+i*2]])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse lcg constant: %w", err)
+		}
+		values[i] = v
+	}
+	params.Multiplier, params.Increment, params.Modulus = values[0], values[1], values[2]
+	if params.Modulus <= 0 {
+		return nil, fmt.Errorf("invalid lcg modulus: %d", params.Modulus)
+	}
+
+	// 种子表达式与 lcg 表达式的形式相同，需要排除 lcg 本身
+	seeds := make([][2]int64, 0)
+	for _, m := range seedRegexp.FindAllStringSubmatchIndex(script, -1) {
+		if m[0] >= lcg[0] && m[0] < lcg[1] {
+			continue
+		}
+		multiplier, err := parseJSNumber(script[m[2]:m[3]])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse seed multiplier: %w", err)
+		}
+		increment, err := parseJSNumber(script[m[4]:m[5]])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse seed increment: %w", err)
+		}
+		seeds = append(seeds, [2]int64{multiplier, increment})
+	}
+	if len(seeds) != 1 {
+		return nil, fmt.Errorf("expected exactly 1 seed expression, found %d", len(seeds))
+	}
+	params.SeedMultiplier, params.SeedIncrement = seeds[0][0], seeds[0][1]
+
+	// 阈值既出现在比较中，也作为偏移量出现在索引计算中
+	offsets := make(map[int64]bool)
+	for _, m := range offsetRegexp.FindAllStringSubmatch(script, -1) {
+		if v, err := parseJSNumber(m[1]); err == nil {
+			offsets[v] = true
+		}
+	}
+	thresholds := make(map[int64]bool)
+	for _, m := range thresholdRegexp.FindAllStringSubmatch(script, -1) {
+		v, err := parseJSNumber(m[1])
+		if err != nil || v <= 1 || !offsets[v] {
+			continue
+		}
+		thresholds[v] = true
+	}
+	if len(thresholds) != 1 {
+		return nil, fmt.Errorf("expected exactly 1 paragraph threshold, found %d", len(thresholds))
+	}
+	for v := range thresholds {
+		params.Threshold = int(v)
+	}
+
+	return params, nil
+}
+
+// chapterlogResult 单个脚本地址的提取结果，done 关闭后 params 与 err 可读
+type chapterlogResult struct {
+	done   chan struct{}
+	params *UnscrambleParams
+	err    error
+}
+
+// getUnscrambleParams 获取页面引用的 chapterlog.js 并提取参数，结果按脚本地址缓存
+//
+// 锁只保护缓存的查找与写入，同一地址的并发请求等待第一个请求的结果
+func (b *Bilinovel) getUnscrambleParams(ctx context.Context, pageUrl string, htmlContent string) (*UnscrambleParams, error) {
+	scriptUrl, err := chapterlogScriptUrl(pageUrl, htmlContent)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		b.chapterlogMu.Lock()
+		result, ok := b.chapterlogCache[scriptUrl]
+		if !ok {
+			result = &chapterlogResult{done: make(chan struct{})}
+			b.chapterlogCache[scriptUrl] = result
+		}
+		b.chapterlogMu.Unlock()
+
+		if !ok {
+			return b.fetchUnscrambleParams(ctx, scriptUrl, result)
+		}
+		select {
+		case <-result.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if !errors.Is(result.err, context.Canceled) && !errors.Is(result.err, context.DeadlineExceeded) {
+			return result.params, result.err
+		}
+		// 先到的请求被取消，本请求重新获取
+	}
+}
+
+// fetchUnscrambleParams 下载脚本并提取参数，完成后唤醒等待同一地址的请求
+func (b *Bilinovel) fetchUnscrambleParams(ctx context.Context, scriptUrl string, result *chapterlogResult) (*UnscrambleParams, error) {
+	defer close(result.done)
+
+	script, err := b.getChapterlogScript(ctx, scriptUrl)
+	if ctx.Err() != nil {
+		// 取消导致的失败不缓存
+		b.chapterlogMu.Lock()
+		delete(b.chapterlogCache, scriptUrl)
+		b.chapterlogMu.Unlock()
+		result.err = ctx.Err()
+		return nil, result.err
+	}
+	var params *UnscrambleParams
+	if err == nil {
+		params, err = ExtractUnscrambleParams(script)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to extract unscramble params from %v: %w", scriptUrl, err)
+		b.logger.Error("Failed to extract unscramble params, falling back to playwright", slog.String("url", scriptUrl), slog.Any("error", err))
+	} else {
+		b.logger.Debug("Extracted unscramble params", slog.String("url", scriptUrl), slog.Any("params", *params))
+	}
+	result.params, result.err = params, err
+	return params, err
+}
+
+// chapterlogScriptUrl 返回页面引用的 chapterlog.js 的完整地址
+func chapterlogScriptUrl(pageUrl string, htmlContent string) (string, error) {
+	m := chapterlogScriptRegexp.FindStringSubmatch(htmlContent)
+	if len(m) != 2 {
+		return "", &downloader.ParseError{Url: pageUrl, Reason: "chapterlog.js not found in page"}
+	}
+	base, err := url.Parse(pageUrl)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse page url: %w", err)
+	}
+	ref, err := url.Parse(m[1])
+	if err != nil {
+		return "", fmt.Errorf("failed to parse chapterlog.js url: %w", err)
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// getChapterlogScript 优先从临时目录读取已缓存的脚本，脚本地址带有版本号，内容变化时地址也会变化
+func (b *Bilinovel) getChapterlogScript(ctx context.Context, scriptUrl string) (string, error) {
+	hash := sha256.Sum256([]byte(scriptUrl))
+	cachePath := filepath.Join(os.TempDir(), "bilinovel-downloader", fmt.Sprintf("chapterlog-%x.js", hash[:8]))
+	if data, err := os.ReadFile(cachePath); err == nil {
+		return string(data), nil
+	}
+
+	resp, err := b.restyClient.R(ctx).SetHeader("Referer", b.site.BaseUrl).Get(scriptUrl)
+	if err != nil {
+		return "", fmt.Errorf("failed to get chapterlog.js: %w", err)
+	}
+	err = checkResponse(resp)
+	if err != nil {
+		return "", fmt.Errorf("failed to get chapterlog.js: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(cachePath), 0755)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	err = os.WriteFile(cachePath, resp.Body(), 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to write chapterlog.js cache: %w", err)
+	}
+	return resp.String(), nil
+}
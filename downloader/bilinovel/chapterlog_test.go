@@ -0,0 +1,97 @@
+package bilinovel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetUnscrambleParamsConcurrent(t *testing.T) {
+	// 脚本缓存在临时目录中，每个测试使用独立的目录
+	t.Setenv("TMPDIR", t.TempDir())
+
+	script := `var _0x1=_0x2*0x7f+0xeb;if(_0x3<=0x14)return;_0x4[_0x5]+0x14;_0x1=(_0x1*0x2456+0xc0f5)%0x38f40;`
+	hang := make(chan struct{})
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow/chapterlog.js":
+			select {
+			case <-hang:
+			case <-r.Context().Done():
+			}
+		case "/fast/chapterlog.js":
+			fetches.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			fmt.Fprint(w, script)
+		}
+	}))
+	defer server.Close()
+	defer close(hang)
+
+	b, err := New(BilinovelNewOption{Renderer: RendererNative, CacheDir: t.TempDir(), ImageDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create bilinovel: %v", err)
+	}
+	page := func(dir string) string {
+		return fmt.Sprintf(`<script src="/%s/chapterlog.js"></script>`, dir)
+	}
+
+	// 一个脚本的请求挂起时，其他脚本的请求不受影响
+	slowCtx, cancelSlow := context.WithCancel(context.Background())
+	slowDone := make(chan error, 1)
+	go func() {
+		_, err := b.getUnscrambleParams(slowCtx, server.URL+"/novel/1/1.html", page("slow"))
+		slowDone <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			params, err := b.getUnscrambleParams(ctx, server.URL+"/novel/1/2.html", page("fast"))
+			if err == nil && *params != DefaultUnscrambleParams {
+				err = fmt.Errorf("unexpected params: %+v", *params)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("failed to get unscramble params: %v", err)
+		}
+	}
+	// 同一脚本的并发请求只下载一次
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("expected 1 fetch, got %d", n)
+	}
+
+	// 取消的请求返回取消错误且不缓存结果
+	cancelSlow()
+	select {
+	case err := <-slowDone:
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("canceled request did not return")
+	}
+	b.chapterlogMu.Lock()
+	_, cached := b.chapterlogCache[server.URL+"/slow/chapterlog.js"]
+	b.chapterlogMu.Unlock()
+	if cached {
+		t.Fatalf("canceled fetch should not be cached")
+	}
+}
@@ -0,0 +1,226 @@
+package bilinovel
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// cssDeclaration 一条 CSS 声明
+type cssDeclaration struct {
+	value     string
+	important bool
+}
+
+// cssRule 样式表中的一条规则，一条规则只对应一个选择器
+type cssRule struct {
+	selector     string
+	specificity  [3]int
+	order        int
+	declarations map[string]cssDeclaration
+}
+
+// styleSheet 静态计算页面内联 CSS，用于代替浏览器中的 window.getComputedStyle
+type styleSheet struct {
+	rules []cssRule
+}
+
+var cssCommentRegexp = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// newStyleSheetFromDocument 收集页面中所有 <style> 元素的规则
+func newStyleSheetFromDocument(doc *goquery.Document) *styleSheet {
+	sheet := &styleSheet{}
+	sheet.parse(styleText(doc))
+	return sheet
+}
+
+// styleText 返回文档中所有 <style> 的内容
+func styleText(doc *goquery.Document) string {
+	var css strings.Builder
+	doc.Find("style").Each(func(i int, s *goquery.Selection) {
+		css.WriteString(s.Text())
+		css.WriteByte('\n')
+	})
+	return css.String()
+}
+
+// parse 解析一段 CSS 文本，@media 等 at-rule 无法静态判断，直接跳过
+func (s *styleSheet) parse(css string) {
+	css = cssCommentRegexp.ReplaceAllString(css, "")
+	for len(css) > 0 {
+		open := strings.IndexByte(css, '{')
+		if open < 0 {
+			return
+		}
+		prelude := strings.TrimSpace(css[:open])
+		end := matchingBrace(css, open)
+		if end < 0 {
+			return
+		}
+		block := css[open+1 : end]
+		css = css[end+1:]
+
+		if strings.HasPrefix(prelude, "@") {
+			continue
+		}
+		// 前一条规则之后可能残留 @import 之类以分号结尾的语句
+		if i := strings.LastIndexByte(prelude, ';'); i >= 0 {
+			prelude = strings.TrimSpace(prelude[i+1:])
+		}
+		declarations := parseDeclarations(block)
+		for _, selector := range strings.Split(prelude, ",") {
+			selector = strings.TrimSpace(selector)
+			if selector == "" {
+				continue
+			}
+			s.rules = append(s.rules, cssRule{
+				selector:     selector,
+				specificity:  selectorSpecificity(selector),
+				order:        len(s.rules),
+				declarations: declarations,
+			})
+		}
+	}
+}
+
+func matchingBrace(css string, open int) int {
+	depth := 0
+	for i := open; i < len(css); i++ {
+		switch css[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func parseDeclarations(block string) map[string]cssDeclaration {
+	declarations := make(map[string]cssDeclaration)
+	for _, decl := range strings.Split(block, ";") {
+		property, value, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		property = strings.ToLower(strings.TrimSpace(property))
+		value = strings.TrimSpace(value)
+		important := false
+		if i := strings.Index(strings.ToLower(value), "!important"); i >= 0 {
+			important = true
+			value = strings.TrimSpace(value[:i])
+		}
+		declarations[property] = cssDeclaration{
+			value:     strings.ToLower(value),
+			important: important,
+		}
+	}
+	return declarations
+}
+
+var (
+	idSelectorRegexp        = regexp.MustCompile(`#[\w-]+`)
+	classSelectorRegexp     = regexp.MustCompile(`\.[\w-]+|\[[^\]]*\]|(^|[^:]):[\w-]+`)
+	typeSelectorRegexp      = regexp.MustCompile(`(^|[\s>+~(])[a-zA-Z][\w-]*`)
+	pseudoElementRegexp     = regexp.MustCompile(`::[\w-]+`)
+	selectorArgumentsRegexp = regexp.MustCompile(`\([^)]*\)`)
+)
+
+// selectorSpecificity 粗略计算选择器优先级 (id, class/属性/伪类, 标签)
+func selectorSpecificity(selector string) [3]int {
+	selector = pseudoElementRegexp.ReplaceAllString(selector, "")
+	selector = selectorArgumentsRegexp.ReplaceAllString(selector, "")
+	return [3]int{
+		len(idSelectorRegexp.FindAllString(selector, -1)),
+		len(classSelectorRegexp.FindAllString(selector, -1)),
+		len(typeSelectorRegexp.FindAllString(selector, -1)),
+	}
+}
+
+// computedValue 按层叠规则计算元素某个属性的最终值，未设置时返回空字符串
+func (s *styleSheet) computedValue(sel *goquery.Selection, property string) string {
+	var (
+		winner      *cssDeclaration
+		winnerRule  *cssRule
+		winnerStyle bool
+	)
+	better := func(decl cssDeclaration, rule *cssRule) bool {
+		if winner == nil {
+			return true
+		}
+		if decl.important != winner.important {
+			return decl.important
+		}
+		if winnerStyle {
+			return false
+		}
+		if rule == nil {
+			return true
+		}
+		if rule.specificity != winnerRule.specificity {
+			return compareSpecificity(rule.specificity, winnerRule.specificity) > 0
+		}
+		return rule.order > winnerRule.order
+	}
+
+	for i := range s.rules {
+		rule := &s.rules[i]
+		decl, ok := rule.declarations[property]
+		if !ok || !sel.Is(rule.selector) {
+			continue
+		}
+		if better(decl, rule) {
+			winner, winnerRule, winnerStyle = &decl, rule, false
+		}
+	}
+
+	// 行内 style 属性的优先级高于所有非 !important 的规则
+	if style, ok := sel.Attr("style"); ok {
+		if decl, ok := parseDeclarations(style)[property]; ok && better(decl, nil) {
+			winner, winnerRule, winnerStyle = &decl, nil, true
+		}
+	}
+
+	if winner == nil {
+		return ""
+	}
+	return winner.value
+}
+
+func compareSpecificity(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return 0
+}
+
+var zeroTransformRegexp = regexp.MustCompile(`^(matrix\((0(\.0+)?,){5}0(\.0+)?\)|scale\(0(\.0+)?(,0(\.0+)?)?\))$`)
+
+// isHidden 与页面中的检测逻辑保持一致：display 为 none 或 transform 为全零矩阵
+func (s *styleSheet) isHidden(sel *goquery.Selection) bool {
+	if s.computedValue(sel, "display") == "none" {
+		return true
+	}
+	transform := strings.Join(strings.Fields(s.computedValue(sel, "transform")), "")
+	return zeroTransformRegexp.MatchString(transform)
+}
+
+// removeHiddenElements 从后往前遍历 root 的所有子元素并移除隐藏元素，返回移除的数量
+func (s *styleSheet) removeHiddenElements(root *goquery.Selection) int {
+	elements := root.Find("*")
+	removedCount := 0
+	for i := elements.Length() - 1; i >= 0; i-- {
+		element := elements.Eq(i)
+		if s.isHidden(element) {
+			element.Remove()
+			removedCount++
+		}
+	}
+	return removedCount
+}
@@ -0,0 +1,73 @@
+package bilinovel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestStyleSheetIsHidden(t *testing.T) {
+	page := `<html><head><style>
+/* 诱饵段落 */
+#acontent p.a { display: none }
+#acontent .b { transform: matrix(0, 0, 0, 0, 0, 0) }
+.c { transform: scale(0) }
+p.d { display: none !important }
+#acontent p.e { display: none }
+p.e { display: block }
+.f { transform: matrix(1, 0, 0, 1, 0, 0) }
+@media screen { p { display: none } }
+</style></head><body><div id="acontent">
+<p id="a" class="a">a</p>
+<p id="b" class="b">b</p>
+<p id="c" class="c">c</p>
+<p id="d" class="d" style="display: block">d</p>
+<p id="e" class="e">e</p>
+<p id="f" class="f">f</p>
+<p id="g" style="display:none">g</p>
+<p id="h" class="a" style="display: block">h</p>
+<p id="i">i</p>
+</div></body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("failed to parse html: %v", err)
+	}
+	sheet := newStyleSheetFromDocument(doc)
+
+	cases := map[string]bool{
+		// display:none
+		"a": true,
+		// 全零矩阵
+		"b": true,
+		"c": true,
+		// !important 优先于行内样式
+		"d": true,
+		// 选择器优先级高的规则生效
+		"e": true,
+		// 非零矩阵不是诱饵
+		"f": false,
+		// 行内样式
+		"g": true,
+		"h": false,
+		// @media 中的规则被跳过
+		"i": false,
+	}
+	for id, hidden := range cases {
+		if got := sheet.isHidden(doc.Find("#" + id)); got != hidden {
+			t.Errorf("isHidden(#%v) = %v, want %v", id, got, hidden)
+		}
+	}
+
+	removed := sheet.removeHiddenElements(doc.Find("#acontent"))
+	if removed != 6 {
+		t.Fatalf("unexpected removed count: %v", removed)
+	}
+	var left []string
+	doc.Find("#acontent p").Each(func(i int, s *goquery.Selection) {
+		left = append(left, s.Text())
+	})
+	if strings.Join(left, "") != "fhi" {
+		t.Fatalf("unexpected paragraphs: %v", left)
+	}
+}
@@ -0,0 +1,531 @@
+package bilinovel
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/dop251/goja"
+	"golang.org/x/net/html"
+)
+
+// jsDOM 为页面脚本提供的最小 DOM 实现，JS 中的节点直接对应 goquery 文档中的 html.Node
+type jsDOM struct {
+	vm      *goja.Runtime
+	doc     *goquery.Document
+	pageUrl string
+	// sheet 由 sheetCSS 解析得到，脚本修改 <style> 后重新解析
+	sheet    *styleSheet
+	sheetCSS string
+
+	objects map[*html.Node]*goja.Object
+	nodes   map[*goja.Object]*html.Node
+
+	// DOMContentLoaded、load 和 setTimeout 的回调，在所有脚本执行完后依次调用
+	pending []goja.Callable
+}
+
+func newJSDOM(vm *goja.Runtime, doc *goquery.Document, pageUrl string) *jsDOM {
+	d := &jsDOM{
+		vm:      vm,
+		doc:     doc,
+		pageUrl: pageUrl,
+		objects: make(map[*html.Node]*goja.Object),
+		nodes:   make(map[*goja.Object]*html.Node),
+	}
+
+	global := vm.GlobalObject()
+	_ = vm.Set("window", global)
+	_ = vm.Set("self", global)
+	_ = vm.Set("document", d.wrap(doc.Nodes[0]))
+
+	navigator := vm.NewObject()
+	_ = navigator.Set("language", "zh-CN")
+	_ = navigator.Set("languages", []string{"zh-CN", "zh"})
+	_ = navigator.Set("platform", "Linux x86_64")
+	_ = navigator.Set("userAgent", "Mozilla/5.0 (X11; Linux x86_64; rv:133.0) Gecko/20100101 Firefox/133.0")
+	_ = vm.Set("navigator", navigator)
+
+	// 页面会根据语言跳转到其他站点，这里禁止任何跳转
+	location := vm.NewObject()
+	_ = location.Set("href", pageUrl)
+	_ = location.Set("replace", func(string) {})
+	_ = location.Set("assign", func(string) {})
+	_ = location.Set("reload", func() {})
+	_ = vm.Set("location", location)
+
+	console := vm.NewObject()
+	for _, name := range []string{"log", "info", "warn", "error", "debug"} {
+		_ = console.Set(name, func(goja.FunctionCall) goja.Value { return goja.Undefined() })
+	}
+	_ = vm.Set("console", console)
+
+	_ = vm.Set("setTimeout", func(call goja.FunctionCall) goja.Value {
+		if fn, ok := goja.AssertFunction(call.Argument(0)); ok {
+			d.pending = append(d.pending, fn)
+		}
+		return vm.ToValue(len(d.pending))
+	})
+	_ = vm.Set("setInterval", func(goja.FunctionCall) goja.Value { return vm.ToValue(0) })
+	_ = vm.Set("clearTimeout", func(goja.FunctionCall) goja.Value { return goja.Undefined() })
+	_ = vm.Set("clearInterval", func(goja.FunctionCall) goja.Value { return goja.Undefined() })
+	_ = vm.Set("addEventListener", d.addEventListener)
+	_ = vm.Set("getComputedStyle", func(call goja.FunctionCall) goja.Value {
+		node := d.unwrap(call.Argument(0))
+		if node == nil {
+			panic(vm.NewTypeError("getComputedStyle: argument is not an element"))
+		}
+		return vm.NewDynamicObject(&computedStyle{dom: d, node: node})
+	})
+
+	return d
+}
+
+// flush 依次执行脚本注册的回调，回调中注册的新回调也会被执行
+func (d *jsDOM) flush() error {
+	for i := 0; i < len(d.pending) && i < 1000; i++ {
+		_, err := d.pending[i](goja.Undefined())
+		if err != nil {
+			return err
+		}
+	}
+	d.pending = nil
+	return nil
+}
+
+func (d *jsDOM) addEventListener(call goja.FunctionCall) goja.Value {
+	switch call.Argument(0).String() {
+	case "DOMContentLoaded", "load", "readystatechange":
+		if fn, ok := goja.AssertFunction(call.Argument(1)); ok {
+			d.pending = append(d.pending, fn)
+		}
+	}
+	return goja.Undefined()
+}
+
+func (d *jsDOM) unwrap(v goja.Value) *html.Node {
+	if o, ok := v.(*goja.Object); ok {
+		return d.nodes[o]
+	}
+	return nil
+}
+
+func (d *jsDOM) wrapAll(nodes []*html.Node) *goja.Object {
+	items := make([]any, len(nodes))
+	for i, n := range nodes {
+		items[i] = d.wrap(n)
+	}
+	return d.vm.NewArray(items...)
+}
+
+func (d *jsDOM) styleSheet() *styleSheet {
+	css := styleText(d.doc)
+	if d.sheet == nil || css != d.sheetCSS {
+		d.sheet = &styleSheet{}
+		d.sheet.parse(css)
+		d.sheetCSS = css
+	}
+	return d.sheet
+}
+
+func (d *jsDOM) accessor(o *goja.Object, name string, get func() any, set func(goja.Value)) {
+	getter := d.vm.ToValue(func(goja.FunctionCall) goja.Value {
+		return d.vm.ToValue(get())
+	})
+	var setter goja.Value
+	if set != nil {
+		setter = d.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+			set(call.Argument(0))
+			return goja.Undefined()
+		})
+	}
+	_ = o.DefineAccessorProperty(name, getter, setter, goja.FLAG_TRUE, goja.FLAG_TRUE)
+}
+
+// wrap 返回节点对应的 JS 对象，同一个节点总是返回同一个对象
+func (d *jsDOM) wrap(n *html.Node) goja.Value {
+	if n == nil {
+		return goja.Null()
+	}
+	if o, ok := d.objects[n]; ok {
+		return o
+	}
+	o := d.vm.NewObject()
+	d.objects[n] = o
+	d.nodes[o] = n
+
+	sel := goquery.NewDocumentFromNode(n).Selection
+
+	switch n.Type {
+	case html.DocumentNode:
+		_ = o.Set("nodeType", 9)
+		_ = o.Set("nodeName", "#document")
+		_ = o.Set("readyState", "loading")
+		_ = o.Set("cookie", "")
+		_ = o.Set("referrer", "")
+		_ = o.Set("getElementById", func(id string) goja.Value {
+			return d.wrap(firstNode(sel.Find("#" + cssEscape(id))))
+		})
+		_ = o.Set("createElement", func(tag string) goja.Value {
+			tag = strings.ToLower(tag)
+			return d.wrap(&html.Node{Type: html.ElementNode, Data: tag})
+		})
+		_ = o.Set("createTextNode", func(text string) goja.Value {
+			return d.wrap(&html.Node{Type: html.TextNode, Data: text})
+		})
+		_ = o.Set("addEventListener", d.addEventListener)
+		d.accessor(o, "documentElement", func() any { return d.wrap(firstNode(sel.Find("html"))) }, nil)
+		d.accessor(o, "head", func() any { return d.wrap(firstNode(sel.Find("head"))) }, nil)
+		d.accessor(o, "body", func() any { return d.wrap(firstNode(sel.Find("body"))) }, nil)
+	case html.ElementNode:
+		_ = o.Set("nodeType", 1)
+		_ = o.Set("nodeName", strings.ToUpper(n.Data))
+		_ = o.Set("tagName", strings.ToUpper(n.Data))
+		d.defineElement(o, n, sel)
+	case html.TextNode:
+		_ = o.Set("nodeType", 3)
+		_ = o.Set("nodeName", "#text")
+		text := func() any { return n.Data }
+		setText := func(v goja.Value) { n.Data = v.String() }
+		d.accessor(o, "data", text, setText)
+		d.accessor(o, "nodeValue", text, setText)
+	case html.CommentNode:
+		_ = o.Set("nodeType", 8)
+		_ = o.Set("nodeName", "#comment")
+		d.accessor(o, "data", func() any { return n.Data }, nil)
+	}
+	d.defineNode(o, n, sel)
+	return o
+}
+
+// defineNode 所有类型节点共有的属性和方法
+func (d *jsDOM) defineNode(o *goja.Object, n *html.Node, sel *goquery.Selection) {
+	d.accessor(o, "parentNode", func() any { return d.wrap(n.Parent) }, nil)
+	d.accessor(o, "parentElement", func() any {
+		if n.Parent != nil && n.Parent.Type == html.ElementNode {
+			return d.wrap(n.Parent)
+		}
+		return goja.Null()
+	}, nil)
+	d.accessor(o, "firstChild", func() any { return d.wrap(n.FirstChild) }, nil)
+	d.accessor(o, "lastChild", func() any { return d.wrap(n.LastChild) }, nil)
+	d.accessor(o, "nextSibling", func() any { return d.wrap(n.NextSibling) }, nil)
+	d.accessor(o, "previousSibling", func() any { return d.wrap(n.PrevSibling) }, nil)
+	d.accessor(o, "childNodes", func() any { return d.wrapAll(childNodes(n, false)) }, nil)
+	d.accessor(o, "children", func() any { return d.wrapAll(childNodes(n, true)) }, nil)
+	d.accessor(o, "firstElementChild", func() any { return d.wrap(firstNode(sel.Children())) }, nil)
+	d.accessor(o, "lastElementChild", func() any { return d.wrap(firstNode(sel.Children().Last())) }, nil)
+	d.accessor(o, "nextElementSibling", func() any { return d.wrap(firstNode(sel.Next())) }, nil)
+	d.accessor(o, "previousElementSibling", func() any { return d.wrap(firstNode(sel.Prev())) }, nil)
+	d.accessor(o, "textContent", func() any { return sel.Text() }, func(v goja.Value) {
+		if n.Type == html.TextNode {
+			n.Data = v.String()
+			return
+		}
+		removeChildren(n)
+		n.AppendChild(&html.Node{Type: html.TextNode, Data: v.String()})
+	})
+
+	_ = o.Set("querySelector", func(selector string) goja.Value {
+		return d.wrap(firstNode(sel.Find(selector)))
+	})
+	_ = o.Set("querySelectorAll", func(selector string) goja.Value {
+		return d.wrapAll(sel.Find(selector).Nodes)
+	})
+	_ = o.Set("getElementsByTagName", func(tag string) goja.Value {
+		return d.wrapAll(sel.Find(tag).Nodes)
+	})
+	_ = o.Set("getElementsByClassName", func(names string) goja.Value {
+		selector := ""
+		for _, name := range strings.Fields(names) {
+			selector += "." + cssEscape(name)
+		}
+		if selector == "" {
+			return d.wrapAll(nil)
+		}
+		return d.wrapAll(sel.Find(selector).Nodes)
+	})
+	_ = o.Set("appendChild", func(call goja.FunctionCall) goja.Value {
+		child := d.unwrap(call.Argument(0))
+		if child == nil {
+			panic(d.vm.NewTypeError("appendChild: argument is not a node"))
+		}
+		detach(child)
+		n.AppendChild(child)
+		return call.Argument(0)
+	})
+	_ = o.Set("insertBefore", func(call goja.FunctionCall) goja.Value {
+		child := d.unwrap(call.Argument(0))
+		if child == nil {
+			panic(d.vm.NewTypeError("insertBefore: argument is not a node"))
+		}
+		ref := d.unwrap(call.Argument(1))
+		detach(child)
+		if ref == nil || ref.Parent != n {
+			n.AppendChild(child)
+		} else {
+			n.InsertBefore(child, ref)
+		}
+		return call.Argument(0)
+	})
+	_ = o.Set("removeChild", func(call goja.FunctionCall) goja.Value {
+		child := d.unwrap(call.Argument(0))
+		if child == nil || child.Parent != n {
+			panic(d.vm.NewTypeError("removeChild: node is not a child of this node"))
+		}
+		n.RemoveChild(child)
+		return call.Argument(0)
+	})
+	_ = o.Set("replaceChild", func(call goja.FunctionCall) goja.Value {
+		newChild := d.unwrap(call.Argument(0))
+		oldChild := d.unwrap(call.Argument(1))
+		if newChild == nil || oldChild == nil || oldChild.Parent != n {
+			panic(d.vm.NewTypeError("replaceChild: invalid arguments"))
+		}
+		detach(newChild)
+		n.InsertBefore(newChild, oldChild)
+		n.RemoveChild(oldChild)
+		return call.Argument(1)
+	})
+	_ = o.Set("remove", func() { detach(n) })
+	_ = o.Set("contains", func(call goja.FunctionCall) goja.Value {
+		for other := d.unwrap(call.Argument(0)); other != nil; other = other.Parent {
+			if other == n {
+				return d.vm.ToValue(true)
+			}
+		}
+		return d.vm.ToValue(false)
+	})
+	_ = o.Set("cloneNode", func(deep bool) goja.Value {
+		return d.wrap(cloneNode(n, deep))
+	})
+	_ = o.Set("hasChildNodes", func() bool { return n.FirstChild != nil })
+	if n.Type != html.DocumentNode {
+		_ = o.Set("addEventListener", func(goja.FunctionCall) goja.Value { return goja.Undefined() })
+	}
+}
+
+// defineElement 元素节点特有的属性和方法
+func (d *jsDOM) defineElement(o *goja.Object, n *html.Node, sel *goquery.Selection) {
+	d.accessor(o, "id", func() any { return sel.AttrOr("id", "") }, func(v goja.Value) { sel.SetAttr("id", v.String()) })
+	d.accessor(o, "className", func() any { return sel.AttrOr("class", "") }, func(v goja.Value) { sel.SetAttr("class", v.String()) })
+	d.accessor(o, "innerText", func() any { return innerText(n) }, func(v goja.Value) {
+		removeChildren(n)
+		n.AppendChild(&html.Node{Type: html.TextNode, Data: v.String()})
+	})
+	d.accessor(o, "innerHTML", func() any {
+		s, _ := sel.Html()
+		return s
+	}, func(v goja.Value) {
+		nodes, err := html.ParseFragment(strings.NewReader(v.String()), n)
+		if err != nil {
+			panic(d.vm.NewGoError(err))
+		}
+		removeChildren(n)
+		for _, child := range nodes {
+			n.AppendChild(child)
+		}
+	})
+	d.accessor(o, "outerHTML", func() any {
+		s, _ := goquery.OuterHtml(sel)
+		return s
+	}, nil)
+	d.accessor(o, "style", func() any {
+		return d.vm.NewDynamicObject(&inlineStyle{vm: d.vm, sel: sel})
+	}, nil)
+
+	_ = o.Set("getAttribute", func(name string) goja.Value {
+		if v, ok := sel.Attr(name); ok {
+			return d.vm.ToValue(v)
+		}
+		return goja.Null()
+	})
+	_ = o.Set("setAttribute", func(name string, value string) { sel.SetAttr(name, value) })
+	_ = o.Set("removeAttribute", func(name string) { sel.RemoveAttr(name) })
+	_ = o.Set("hasAttribute", func(name string) bool {
+		_, ok := sel.Attr(name)
+		return ok
+	})
+	_ = o.Set("matches", func(selector string) bool { return sel.Is(selector) })
+
+	classList := d.vm.NewObject()
+	_ = classList.Set("contains", func(name string) bool { return sel.HasClass(name) })
+	_ = classList.Set("add", func(names ...string) { sel.AddClass(names...) })
+	_ = classList.Set("remove", func(names ...string) { sel.RemoveClass(names...) })
+	_ = o.Set("classList", classList)
+}
+
+// inlineStyle 对应 element.style，读写元素的 style 属性
+type inlineStyle struct {
+	vm  *goja.Runtime
+	sel *goquery.Selection
+}
+
+var camelCaseRegexp = regexp.MustCompile(`[A-Z]`)
+
+func cssPropertyName(key string) string {
+	return camelCaseRegexp.ReplaceAllStringFunc(key, func(s string) string {
+		return "-" + strings.ToLower(s)
+	})
+}
+
+func (s *inlineStyle) declarations() [][2]string {
+	result := make([][2]string, 0)
+	for _, decl := range strings.Split(s.sel.AttrOr("style", ""), ";") {
+		property, value, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		result = append(result, [2]string{strings.ToLower(strings.TrimSpace(property)), strings.TrimSpace(value)})
+	}
+	return result
+}
+
+func (s *inlineStyle) Get(key string) goja.Value {
+	if key == "cssText" {
+		return s.vm.ToValue(s.sel.AttrOr("style", ""))
+	}
+	property := cssPropertyName(key)
+	for _, decl := range s.declarations() {
+		if decl[0] == property {
+			return s.vm.ToValue(decl[1])
+		}
+	}
+	return s.vm.ToValue("")
+}
+
+func (s *inlineStyle) Set(key string, val goja.Value) bool {
+	if key == "cssText" {
+		s.sel.SetAttr("style", val.String())
+		return true
+	}
+	property := cssPropertyName(key)
+	builder := strings.Builder{}
+	for _, decl := range s.declarations() {
+		if decl[0] == property {
+			continue
+		}
+		builder.WriteString(decl[0] + ": " + decl[1] + "; ")
+	}
+	if v := val.String(); v != "" {
+		builder.WriteString(property + ": " + v + ";")
+	}
+	s.sel.SetAttr("style", strings.TrimSpace(builder.String()))
+	return true
+}
+
+func (s *inlineStyle) Has(key string) bool    { return true }
+func (s *inlineStyle) Delete(key string) bool { return s.Set(key, s.vm.ToValue("")) }
+func (s *inlineStyle) Keys() []string {
+	keys := make([]string, 0)
+	for _, decl := range s.declarations() {
+		keys = append(keys, decl[0])
+	}
+	return keys
+}
+
+// computedStyle 对应 window.getComputedStyle 的返回值，由页面内联 CSS 静态计算
+type computedStyle struct {
+	dom  *jsDOM
+	node *html.Node
+}
+
+func (c *computedStyle) value(property string) string {
+	sel := goquery.NewDocumentFromNode(c.node).Selection
+	return c.dom.styleSheet().computedValue(sel, property)
+}
+
+func (c *computedStyle) Get(key string) goja.Value {
+	if key == "getPropertyValue" {
+		return c.dom.vm.ToValue(func(property string) string { return c.value(property) })
+	}
+	return c.dom.vm.ToValue(c.value(cssPropertyName(key)))
+}
+
+func (c *computedStyle) Set(key string, val goja.Value) bool { return false }
+func (c *computedStyle) Has(key string) bool                 { return true }
+func (c *computedStyle) Delete(key string) bool              { return false }
+func (c *computedStyle) Keys() []string                      { return nil }
+
+func firstNode(sel *goquery.Selection) *html.Node {
+	if sel.Length() == 0 {
+		return nil
+	}
+	return sel.Nodes[0]
+}
+
+func childNodes(n *html.Node, elementsOnly bool) []*html.Node {
+	nodes := make([]*html.Node, 0)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if elementsOnly && c.Type != html.ElementNode {
+			continue
+		}
+		nodes = append(nodes, c)
+	}
+	return nodes
+}
+
+func detach(n *html.Node) {
+	if n.Parent != nil {
+		n.Parent.RemoveChild(n)
+	}
+}
+
+func removeChildren(n *html.Node) {
+	for n.FirstChild != nil {
+		n.RemoveChild(n.FirstChild)
+	}
+}
+
+func cloneNode(n *html.Node, deep bool) *html.Node {
+	clone := &html.Node{
+		Type:      n.Type,
+		DataAtom:  n.DataAtom,
+		Data:      n.Data,
+		Namespace: n.Namespace,
+		Attr:      append([]html.Attribute(nil), n.Attr...),
+	}
+	if deep {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			clone.AppendChild(cloneNode(c, true))
+		}
+	}
+	return clone
+}
+
+// innerText 与浏览器一致，<br> 与块级段落之间以换行分隔
+func innerText(n *html.Node) string {
+	builder := strings.Builder{}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			builder.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			switch n.Data {
+			case "br":
+				builder.WriteString("\n")
+				return
+			case "script", "style":
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if n.Type == html.ElementNode && (n.Data == "p" || n.Data == "div") {
+			builder.WriteString("\n")
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c)
+	}
+	return builder.String()
+}
+
+var cssEscapeRegexp = regexp.MustCompile(`([^\w-])`)
+
+func cssEscape(s string) string {
+	return cssEscapeRegexp.ReplaceAllString(s, `\$1`)
+}
@@ -0,0 +1,52 @@
+package bilinovel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/dop251/goja"
+)
+
+func TestComputedStyleFollowsStyleChanges(t *testing.T) {
+	page := `<html><head><style>.a { display: none }</style></head><body><p class="a">a</p></body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("failed to parse html: %v", err)
+	}
+	vm := goja.New()
+	newJSDOM(vm, doc, "https://example.com/novel/1/1.html")
+
+	display := func() string {
+		v, err := vm.RunString(`getComputedStyle(document.querySelector(".a")).display`)
+		if err != nil {
+			t.Fatalf("failed to run script: %v", err)
+		}
+		return v.String()
+	}
+
+	if d := display(); d != "none" {
+		t.Fatalf("expected display none, got %q", d)
+	}
+
+	// 脚本注入的 <style> 在后续的 getComputedStyle 中生效
+	_, err = vm.RunString(`
+var style = document.createElement("style");
+style.textContent = ".a { display: block }";
+document.head.appendChild(style);`)
+	if err != nil {
+		t.Fatalf("failed to inject style: %v", err)
+	}
+	if d := display(); d != "block" {
+		t.Fatalf("expected display block after injection, got %q", d)
+	}
+
+	// 修改已有 <style> 的内容同样生效
+	_, err = vm.RunString(`style.textContent = ".a { display: inline }";`)
+	if err != nil {
+		t.Fatalf("failed to mutate style: %v", err)
+	}
+	if d := display(); d != "inline" {
+		t.Fatalf("expected display inline after mutation, got %q", d)
+	}
+}
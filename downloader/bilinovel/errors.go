@@ -0,0 +1,12 @@
+package bilinovel
+
+import (
+	"bilinovel-downloader/downloader"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// checkResponse 状态码不是 200 或者是反爬验证页面时返回 downloader.StatusError
+func checkResponse(resp *resty.Response) error {
+	return downloader.CheckResponse(resp.Request.URL, resp.StatusCode(), resp.Header(), resp.Body())
+}
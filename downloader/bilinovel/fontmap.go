@@ -0,0 +1,274 @@
+package bilinovel
+
+import (
+	"bilinovel-downloader/fonts"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	mapper "git.nite07.com/nite/font-mapper"
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// defaultFontFamily 内置的 read.ttf 对应的字体名，页面使用这个字体但没有声明 @font-face 时使用内置字体
+const defaultFontFamily = "read"
+
+var (
+	fontFaceRegexp   = regexp.MustCompile(`(?is)@font-face\s*\{([^{}]*)\}`)
+	fontFamilyRegexp = regexp.MustCompile(`(?i)font-family\s*:\s*([^;{}]+)`)
+	fontSrcRegexp    = regexp.MustCompile(`(?i)url\(\s*['"]?([^'")]+)['"]?\s*\)(?:\s*format\(\s*['"]?([^'")]+)['"]?\s*\))?`)
+	cssRuleRegexp    = regexp.MustCompile(`(?s)([^{}@;]+)\{([^{}]*)\}`)
+)
+
+// fontFace 页面通过 @font-face 声明的字体
+type fontFace struct {
+	family string
+	url    string
+}
+
+// fontRule 使用混淆字体的元素，selector 为空时表示 element 中的元素
+type fontRule struct {
+	family   string
+	selector string
+	element  *goquery.Selection
+}
+
+// parseFontFamily 返回 font-family 声明的第一个字体名
+func parseFontFamily(value string) string {
+	value = strings.TrimSpace(strings.Split(value, ",")[0])
+	value = strings.TrimSpace(strings.TrimSuffix(value, "!important"))
+	return strings.Trim(value, `'" `)
+}
+
+// parseFontFaces 从 CSS 中提取 @font-face 声明，一个字体有多个来源时优先使用 TrueType 和 OpenType 格式
+func parseFontFaces(css string, pageUrl *url.URL) []fontFace {
+	faces := make([]fontFace, 0)
+	for _, m := range fontFaceRegexp.FindAllStringSubmatch(css, -1) {
+		family := fontFamilyRegexp.FindStringSubmatch(m[1])
+		if family == nil {
+			continue
+		}
+		src := ""
+		for _, s := range fontSrcRegexp.FindAllStringSubmatch(m[1], -1) {
+			format := strings.ToLower(s[2])
+			if src == "" || format == "truetype" || format == "opentype" {
+				src = s[1]
+			}
+			if format == "truetype" || format == "opentype" {
+				break
+			}
+		}
+		if src == "" {
+			continue
+		}
+		ref, err := url.Parse(src)
+		if err != nil {
+			continue
+		}
+		faces = append(faces, fontFace{
+			family: parseFontFamily(family[1]),
+			url:    pageUrl.ResolveReference(ref).String(),
+		})
+	}
+	return faces
+}
+
+// findFontRules 找出页面样式表和 style 属性中使用了 families 中字体的元素
+func findFontRules(doc *goquery.Document, families map[string]bool) []fontRule {
+	rules := make([]fontRule, 0)
+	doc.Find("style").Each(func(i int, s *goquery.Selection) {
+		css := fontFaceRegexp.ReplaceAllString(s.Text(), "")
+		for _, m := range cssRuleRegexp.FindAllStringSubmatch(css, -1) {
+			family := fontFamilyRegexp.FindStringSubmatch(m[2])
+			if family == nil || !families[parseFontFamily(family[1])] {
+				continue
+			}
+			for _, selector := range strings.Split(m[1], ",") {
+				rules = append(rules, fontRule{family: parseFontFamily(family[1]), selector: strings.TrimSpace(selector)})
+			}
+		}
+	})
+	doc.Find("[style]").Each(func(i int, s *goquery.Selection) {
+		family := fontFamilyRegexp.FindStringSubmatch(s.AttrOr("style", ""))
+		if family != nil && families[parseFontFamily(family[1])] {
+			rules = append(rules, fontRule{family: parseFontFamily(family[1]), element: s})
+		}
+	})
+	return rules
+}
+
+// deobfuscate 找出正文中使用混淆字体的元素，用对应字体的映射还原文字。
+// 字体从页面的 @font-face 中发现，下载后按地址缓存，没有声明 @font-face 的 read 字体使用内置的 read.ttf
+func (b *Bilinovel) deobfuscate(ctx context.Context, doc *goquery.Document, content *goquery.Selection, pageUrl string, pageHtml string) error {
+	base, err := url.Parse(pageUrl)
+	if err != nil {
+		return fmt.Errorf("failed to parse page url: %w", err)
+	}
+	css := strings.Builder{}
+	doc.Find("style").Each(func(i int, s *goquery.Selection) {
+		css.WriteString(s.Text())
+	})
+
+	mappers := make(map[string]*mapper.GlyphOutlineMapper)
+	families := make(map[string]bool)
+	for _, face := range parseFontFaces(css.String(), base) {
+		m, err := b.getFontMapper(ctx, face.url)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			b.logger.Warn("Failed to load obfuscation font", slog.String("family", face.family), slog.String("url", face.url), slog.Any("error", err))
+			continue
+		}
+		mappers[face.family] = m
+		families[face.family] = true
+	}
+	if _, ok := mappers[defaultFontFamily]; !ok {
+		mappers[defaultFontFamily] = b.fontMapper
+		families[defaultFontFamily] = true
+	}
+
+	// 记录每个匹配的元素使用的字体，后面的规则和 style 属性覆盖前面的规则
+	elementFamilies := make(map[*html.Node]string)
+	for _, rule := range findFontRules(doc, families) {
+		elements := rule.element
+		if elements == nil {
+			elements = content.Find(rule.selector).AddSelection(content.Filter(rule.selector))
+		} else if !contains(content, elements.Nodes[0]) {
+			continue
+		}
+		for _, node := range elements.Nodes {
+			elementFamilies[node] = rule.family
+		}
+	}
+	dropped := 0
+	for _, node := range content.Nodes {
+		dropped += mapFontText(node, elementFamilies, mappers, nil)
+	}
+
+	// 旧版页面只在最后一个段落使用 read 字体，没有可以匹配的样式规则
+	if len(elementFamilies) == 0 && strings.Contains(pageHtml, `font-family: "read"`) {
+		dropped += mapText(content.Find("p").Last(), b.fontMapper)
+	}
+	if dropped > 0 {
+		b.logger.Warn("Dropped characters that can not be mapped", slog.String("url", pageUrl), slog.Int("count", dropped))
+	}
+	return nil
+}
+
+// contains 判断节点是否是 s 中的元素或者它们的子孙节点
+func contains(s *goquery.Selection, node *html.Node) bool {
+	for ; node != nil; node = node.Parent {
+		if slices.Contains(s.Nodes, node) {
+			return true
+		}
+	}
+	return false
+}
+
+// mapFontText 与 CSS 的继承一致，用最近的匹配元素的字体映射文本节点，嵌套的匹配元素中的文本节点也只映射一次
+func mapFontText(node *html.Node, elementFamilies map[*html.Node]string, mappers map[string]*mapper.GlyphOutlineMapper, m *mapper.GlyphOutlineMapper) int {
+	if family, ok := elementFamilies[node]; ok {
+		m = mappers[family]
+	}
+	if node.Type == html.TextNode {
+		if m == nil {
+			return 0
+		}
+		return mapNode(node, m)
+	}
+	dropped := 0
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		dropped += mapFontText(child, elementFamilies, mappers, m)
+	}
+	return dropped
+}
+
+// mapText 将元素中所有文本节点的字符替换为映射后的字符，无法映射的字符会被丢弃，返回丢弃的字符数
+func mapText(s *goquery.Selection, m *mapper.GlyphOutlineMapper) int {
+	dropped := 0
+	for _, node := range s.Nodes {
+		dropped += mapNode(node, m)
+	}
+	return dropped
+}
+
+func mapNode(node *html.Node, m *mapper.GlyphOutlineMapper) int {
+	dropped := 0
+	if node.Type == html.TextNode {
+		builder := strings.Builder{}
+		for _, r := range node.Data {
+			_, newRune, ok := m.MappingRune(r)
+			if ok {
+				builder.WriteRune(newRune)
+			} else if !unicode.IsSpace(r) {
+				dropped++
+			}
+		}
+		node.Data = builder.String()
+		return dropped
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		dropped += mapNode(child, m)
+	}
+	return dropped
+}
+
+// getFontMapper 返回字体地址对应的映射，字体文件缓存在磁盘上，映射缓存在内存中
+func (b *Bilinovel) getFontMapper(ctx context.Context, fontUrl string) (*mapper.GlyphOutlineMapper, error) {
+	b.fontMu.Lock()
+	m, ok := b.fontMappers[fontUrl]
+	b.fontMu.Unlock()
+	if ok {
+		return m, nil
+	}
+
+	sum := sha256.Sum256([]byte(fontUrl))
+	path := filepath.Join(b.fontDir, hex.EncodeToString(sum[:])+filepath.Ext(strings.SplitN(fontUrl, "?", 2)[0]))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		data, err = b.getFont(ctx, fontUrl)
+		if err != nil {
+			return nil, err
+		}
+		err = os.MkdirAll(b.fontDir, 0755)
+		if err == nil {
+			err = os.WriteFile(path, data, 0644)
+		}
+		if err != nil {
+			b.logger.Warn("Failed to cache font", slog.String("url", fontUrl), slog.Any("error", err))
+		}
+	}
+
+	m, err = mapper.NewGlyphOutlineMapper(data, fonts.MiLanting)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create font mapper: %w", err)
+	}
+	b.fontMu.Lock()
+	b.fontMappers[fontUrl] = m
+	b.fontMu.Unlock()
+	return m, nil
+}
+
+func (b *Bilinovel) getFont(ctx context.Context, fontUrl string) ([]byte, error) {
+	b.logger.Info("Getting font", slog.String("url", fontUrl))
+	resp, err := b.restyClient.R(ctx).SetHeader("Referer", b.site.BaseUrl).Get(fontUrl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get font: %w", err)
+	}
+	err = checkResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get font: %w", err)
+	}
+	return resp.Body(), nil
+}
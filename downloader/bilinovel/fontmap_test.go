@@ -0,0 +1,69 @@
+package bilinovel
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestParseFontFaces(t *testing.T) {
+	pageUrl, _ := url.Parse("https://www.bilinovel.com/novel/2388/84522.html")
+	css := `
+@font-face {
+	font-family: "read";
+	src: url('/public/font/read.woff2') format('woff2'), url("/public/font/read.ttf") format("truetype");
+}
+@font-face { font-family: 'other', serif; src: url(https://cdn.example.com/other.woff2) }
+@font-face { font-family: nosrc; }
+@font-face { src: url(/public/font/nofamily.ttf) }
+#acontent p { font-family: "read" }`
+
+	faces := parseFontFaces(css, pageUrl)
+	want := []fontFace{
+		// 优先使用 TrueType 格式，相对地址按页面地址解析
+		{family: "read", url: "https://www.bilinovel.com/public/font/read.ttf"},
+		{family: "other", url: "https://cdn.example.com/other.woff2"},
+	}
+	if len(faces) != len(want) {
+		t.Fatalf("unexpected faces: %+v", faces)
+	}
+	for i := range want {
+		if faces[i] != want[i] {
+			t.Fatalf("unexpected face %v: %+v", i, faces[i])
+		}
+	}
+}
+
+func TestFindFontRules(t *testing.T) {
+	page := `<html><head><style>
+@font-face { font-family: "read"; src: url(/public/font/read.ttf) }
+#acontent p:last-of-type, .obf { font-family: "read", sans-serif !important }
+.plain { font-family: serif }
+</style></head><body><div id="acontent">
+<p>a</p>
+<p style="color: red; font-family: 'other'">b</p>
+<p style="font-family: serif">c</p>
+</div></body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("failed to parse html: %v", err)
+	}
+
+	rules := findFontRules(doc, map[string]bool{"read": true, "other": true})
+	if len(rules) != 3 {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+	// @font-face 中的 font-family 不是使用字体的规则
+	if rules[0].family != "read" || rules[0].selector != "#acontent p:last-of-type" || rules[0].element != nil {
+		t.Fatalf("unexpected rule 0: %+v", rules[0])
+	}
+	if rules[1].family != "read" || rules[1].selector != ".obf" {
+		t.Fatalf("unexpected rule 1: %+v", rules[1])
+	}
+	// style 属性中使用的字体
+	if rules[2].family != "other" || rules[2].element == nil || rules[2].element.Text() != "b" {
+		t.Fatalf("unexpected rule 2: %+v", rules[2])
+	}
+}
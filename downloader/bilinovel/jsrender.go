@@ -0,0 +1,90 @@
+package bilinovel
+
+import (
+	"bilinovel-downloader/downloader"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/dop251/goja"
+)
+
+// processContentWithJS 在内嵌的 JS 引擎中按文档顺序执行页面的内联脚本和 chapterlog.js，
+// 相比 native 不依赖对算法的手工复刻，相比 playwright 不需要浏览器
+func (b *Bilinovel) processContentWithJS(ctx context.Context, pageUrl string, htmlContent string) (string, error) {
+	scriptUrl, err := chapterlogScriptUrl(pageUrl, htmlContent)
+	if err != nil {
+		return "", err
+	}
+	chapterlog, err := b.getChapterlogScript(ctx, scriptUrl)
+	if err != nil {
+		return "", err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", &downloader.ParseError{Url: pageUrl, Reason: fmt.Sprintf("failed to parse html: %v", err)}
+	}
+	if doc.Find(b.site.Selectors.Content).Length() == 0 {
+		return "", &downloader.ParseError{Url: pageUrl, Selector: b.site.Selectors.Content, Reason: "content element not found"}
+	}
+
+	vm := goja.New()
+	dom := newJSDOM(vm, doc, pageUrl)
+
+	// 与 playwright 的等待时间保持一致，防止脚本死循环，ctx 取消时同样中断脚本
+	timer := time.AfterFunc(b.renderTimeout, func() {
+		vm.Interrupt("script execution timeout")
+	})
+	defer timer.Stop()
+	stop := context.AfterFunc(ctx, func() {
+		vm.Interrupt(ctx.Err())
+	})
+	defer stop()
+
+	executed := false
+	for _, script := range doc.Find("script").Nodes {
+		sel := goquery.NewDocumentFromNode(script).Selection
+		src, hasSrc := sel.Attr("src")
+		switch {
+		case !hasSrc:
+			// 内联脚本大多依赖 jQuery 等外部脚本，失败不影响重排，只需要其中的 ReadParams
+			_, err := vm.RunScript("inline", sel.Text())
+			if err != nil {
+				if _, ok := err.(*goja.InterruptedError); ok {
+					return "", fmt.Errorf("failed to run inline script: %w", err)
+				}
+				b.logger.Debug("Inline script failed", slog.Any("error", err))
+			}
+		case strings.Contains(src, "chapterlog.js"):
+			_, err := vm.RunScript(scriptUrl, chapterlog)
+			if err != nil {
+				return "", fmt.Errorf("failed to run chapterlog.js: %w", err)
+			}
+			executed = true
+		}
+	}
+	if !executed {
+		return "", &downloader.ParseError{Url: pageUrl, Reason: "chapterlog.js was not executed"}
+	}
+
+	_ = vm.GlobalObject().Get("document").ToObject(vm).Set("readyState", "complete")
+	err = dom.flush()
+	if err != nil {
+		return "", fmt.Errorf("failed to run page callbacks: %w", err)
+	}
+
+	// 脚本可能修改了样式，重新计算后移除隐藏元素
+	sheet := newStyleSheetFromDocument(doc)
+	removedCount := sheet.removeHiddenElements(doc.Find(b.site.Selectors.Content).First())
+	b.logger.Debug("Hidden elements removal result", slog.Int("count", removedCount))
+
+	processedHTML, err := doc.Html()
+	if err != nil {
+		return "", fmt.Errorf("failed to render html: %w", err)
+	}
+	return processedHTML, nil
+}
@@ -0,0 +1,91 @@
+package bilinovel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// testChapterlog 按 chapterlog.js 的算法重排段落，并在页面加载后把一个诱饵段落缩放为零
+const testChapterlog = `
+document.addEventListener("DOMContentLoaded", function () {
+	var chapterId = parseInt(ReadParams.chapterid);
+	var content = document.querySelector("#acontent");
+	var elements = [];
+	content.querySelectorAll("p").forEach(function (p) {
+		if (p.innerText.trim().length > 0) elements.push(p);
+	});
+	var j = elements.length;
+	if (j <= 2) return;
+	var ms = chapterId * 127 + 235;
+	var value = [];
+	for (var i = 0; i < j - 2; i++) value.push(i);
+	for (var i = value.length - 1; i > 0; i--) {
+		ms = (ms * 9302 + 49397) % 233280;
+		var prop = Math.floor(ms / 233280 * (i + 1));
+		var tmp = value[i]; value[i] = value[prop]; value[prop] = tmp;
+	}
+	var aProperties = [0, 1];
+	for (var i = 0; i < value.length; i++) aProperties.push(value[i] + 2);
+	var ordered = [];
+	for (var i = 0; i < j; i++) ordered[aProperties[i]] = elements[i];
+	for (var i = 0; i < j; i++) content.appendChild(ordered[i]);
+	document.querySelector(".late").style.transform = "matrix(0, 0, 0, 0, 0, 0)";
+});`
+
+// testChapterPage 诱饵段落同样参与重排，正确顺序为 1、2、3、decoy、4、late、5，.decoy 由样式表隐藏
+const testChapterPage = `<html><head>
+<style>#acontent .decoy { display: none }</style>
+<script>var ReadParams = { chapterid: '1' };</script>
+<script>jQuery(function () {});</script>
+</head><body>
+<div id="acontent">
+<p>1</p>
+<p>2</p>
+<p>4</p>
+<p>3</p>
+<p>5</p>
+<p class="decoy">decoy</p>
+<p class="late">late</p>
+</div>
+<script src="/themes/zhmb/js/chapterlog.js?v1006c1"></script>
+</body></html>`
+
+func TestProcessContentWithJS(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/themes/zhmb/js/chapterlog.js" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(testChapterlog))
+	}))
+	defer server.Close()
+
+	b, err := New(BilinovelNewOption{Renderer: RendererJS, CacheDir: t.TempDir(), ImageDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create downloader: %v", err)
+	}
+	defer b.Close()
+
+	processed, err := b.processContentWithJS(context.Background(), server.URL+"/novel/1/1.html", testChapterPage)
+	if err != nil {
+		t.Fatalf("failed to process content: %v", err)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(processed))
+	if err != nil {
+		t.Fatalf("failed to parse html: %v", err)
+	}
+	var paragraphs []string
+	doc.Find("#acontent p").Each(func(i int, s *goquery.Selection) {
+		paragraphs = append(paragraphs, s.Text())
+	})
+	// 样式表和脚本隐藏的诱饵段落都被移除
+	if strings.Join(paragraphs, ",") != "1,2,3,4,5" {
+		t.Fatalf("unexpected paragraphs: %v", paragraphs)
+	}
+}
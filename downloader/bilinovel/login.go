@@ -0,0 +1,66 @@
+package bilinovel
+
+import (
+	"bilinovel-downloader/downloader"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// vipChapterRegexp 页面 ReadParams 中的 chapterisvip 为 1 时章节需要登录后才能阅读
+var vipChapterRegexp = regexp.MustCompile(`chapterisvip\s*[:=]\s*['"]?1\b`)
+
+// checkLogin 检查章节页面是否被重定向到登录页，或者包含站点定义中的需要登录的提示
+func (b *Bilinovel) checkLogin(path string, page string) error {
+	if strings.Contains(strings.ToLower(path), "login") {
+		return b.loginError()
+	}
+	for _, marker := range b.site.LoginMarkers {
+		if strings.Contains(page, marker) {
+			return b.loginError()
+		}
+	}
+	return nil
+}
+
+// loginError 导入了 cookie 时说明会话已经失效，否则说明需要导入 cookie
+func (b *Bilinovel) loginError() error {
+	if len(b.cookies) > 0 {
+		return downloader.ErrSessionExpired
+	}
+	return downloader.ErrLoginRequired
+}
+
+// browserCookies 将导入的 cookie 转换为浏览器的 cookie
+func browserCookies(cookies []*http.Cookie) []playwright.OptionalCookie {
+	result := make([]playwright.OptionalCookie, 0, len(cookies))
+	for _, cookie := range cookies {
+		path := cookie.Path
+		if path == "" {
+			path = "/"
+		}
+		c := playwright.OptionalCookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   playwright.String(cookie.Domain),
+			Path:     playwright.String(path),
+			HttpOnly: playwright.Bool(cookie.HttpOnly),
+			Secure:   playwright.Bool(cookie.Secure),
+		}
+		if !cookie.Expires.IsZero() {
+			c.Expires = playwright.Float(float64(cookie.Expires.Unix()))
+		}
+		switch cookie.SameSite {
+		case http.SameSiteStrictMode:
+			c.SameSite = playwright.SameSiteAttributeStrict
+		case http.SameSiteLaxMode:
+			c.SameSite = playwright.SameSiteAttributeLax
+		case http.SameSiteNoneMode:
+			c.SameSite = playwright.SameSiteAttributeNone
+		}
+		result = append(result, c)
+	}
+	return result
+}
@@ -0,0 +1,116 @@
+package bilinovel
+
+import (
+	"bilinovel-downloader/downloader"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+var chapterIdRegexp = regexp.MustCompile(`chapterid:\s?['"](\d+)['"]`)
+
+// processContentNative 不启动浏览器，直接在 Go 中还原段落顺序并移除诱饵段落
+func (b *Bilinovel) processContentNative(htmlContent string, chapterId int, params UnscrambleParams) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", &downloader.ParseError{Reason: fmt.Sprintf("failed to parse html: %v", err)}
+	}
+
+	// 页面中的 ReadParams.chapterid 才是 chapterlog.js 实际使用的种子来源
+	if m := chapterIdRegexp.FindStringSubmatch(htmlContent); len(m) == 2 {
+		if id, err := strconv.Atoi(m[1]); err == nil {
+			chapterId = id
+		}
+	}
+
+	content := doc.Find(b.site.Selectors.Content).First()
+	if content.Length() == 0 {
+		return "", &downloader.ParseError{Selector: b.site.Selectors.Content, Reason: "content element not found"}
+	}
+
+	// 与浏览器中的执行顺序一致：先由 chapterlog.js 重排，再移除隐藏元素
+	reorderParagraphs(content, chapterId, params)
+
+	sheet := newStyleSheetFromDocument(doc)
+	removedCount := sheet.removeHiddenElements(content)
+	b.logger.Debug("Hidden elements removal result", slog.Int("count", removedCount))
+
+	processedHTML, err := doc.Html()
+	if err != nil {
+		return "", fmt.Errorf("failed to render html: %w", err)
+	}
+	return processedHTML, nil
+}
+
+// reorderParagraphs 将正文中的段落按正确顺序放回原来的位置
+func reorderParagraphs(content *goquery.Selection, chapterId int, params UnscrambleParams) {
+	var scrambled []*goquery.Selection
+	content.Find("p").Each(func(i int, s *goquery.Selection) {
+		// 与 JS 逻辑保持一致，只处理非空段落
+		if len(strings.TrimSpace(s.Text())) > 0 {
+			scrambled = append(scrambled, s)
+		}
+	})
+	ordered := UnscrambleParagraphs(scrambled, chapterId, params)
+
+	// 先用占位节点记住每个段落所在的位置，再把排好序的段落依次放回
+	placeholders := make([]*html.Node, len(scrambled))
+	for i, s := range scrambled {
+		node := s.Nodes[0]
+		placeholders[i] = &html.Node{Type: html.CommentNode}
+		node.Parent.InsertBefore(placeholders[i], node)
+		node.Parent.RemoveChild(node)
+	}
+	for i, placeholder := range placeholders {
+		placeholder.Parent.InsertBefore(ordered[i].Nodes[0], placeholder)
+		placeholder.Parent.RemoveChild(placeholder)
+	}
+}
+
+// UnscrambleParagraphs 接收一个乱序的段落列表，并根据 chapterId 将它们重新排序为正确的阅读顺序。
+// 算法来源 https://www.bilinovel.com/themes/zhmb/js/chapterlog.js?v1006c1 ，参数由 ExtractUnscrambleParams 从脚本中提取
+func UnscrambleParagraphs(scrambledParagraphs []*goquery.Selection, chapterId int, params UnscrambleParams) []*goquery.Selection {
+	j := len(scrambledParagraphs)
+	threshold := params.Threshold
+	// 根据JS逻辑，如果段落数小于等于阈值，则不进行排序
+	if j <= threshold {
+		return scrambledParagraphs
+	}
+
+	// 初始化种子
+	ms := int64(chapterId)*params.SeedMultiplier + params.SeedIncrement
+
+	// value 数组存放的是需要被打乱的、从阈值开始的段落的相对索引（0, 1, 2...）
+	value := make([]int, j-threshold)
+	for i := range value {
+		value[i] = i
+	}
+
+	// 执行与JS完全相同的 Fisher-Yates-like 洗牌算法
+	for i := len(value) - 1; i > 0; i-- {
+		ms = (ms*params.Multiplier + params.Increment) % params.Modulus
+		prop := int(float64(ms) / float64(params.Modulus) * float64(i+1))
+		value[i], value[prop] = value[prop], value[i]
+	}
+
+	// 乱序列表中的第 i 项应该被放到正确顺序列表的 aProperties[i] 位置
+	aProperties := make([]int, j)
+	for i := range threshold {
+		aProperties[i] = i
+	}
+	for i := range value {
+		aProperties[i+threshold] = value[i] + threshold
+	}
+
+	correctlyOrdered := make([]*goquery.Selection, j)
+	for i := range j {
+		correctlyOrdered[aProperties[i]] = scrambledParagraphs[i]
+	}
+
+	return correctlyOrdered
+}
@@ -0,0 +1,48 @@
+package bilinovel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestReorderParagraphs(t *testing.T) {
+	// chapterId 为 1、阈值为 2 时，乱序的第 3、4、5 段分别属于第 5、3、4 段
+	page := `<div id="acontent">
+<p>1</p>
+<p>2</p>
+<div class="ad">ad</div>
+<p>5</p>
+<p> </p>
+<p>3</p>
+<p>4</p>
+</div>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("failed to parse html: %v", err)
+	}
+	params := DefaultUnscrambleParams
+	params.Threshold = 2
+	reorderParagraphs(doc.Find("#acontent"), 1, params)
+
+	var children []string
+	doc.Find("#acontent").Children().Each(func(i int, s *goquery.Selection) {
+		children = append(children, goquery.NodeName(s)+":"+strings.TrimSpace(s.Text()))
+	})
+	// 段落按正确顺序放回原来的位置，空段落和其他元素的位置不变
+	want := []string{"p:1", "p:2", "div:ad", "p:3", "p:", "p:4", "p:5"}
+	if strings.Join(children, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected order: %v", children)
+	}
+
+	// 段落数不超过阈值时不重排
+	doc, err = goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("failed to parse html: %v", err)
+	}
+	reorderParagraphs(doc.Find("#acontent"), 1, DefaultUnscrambleParams)
+	if text := strings.Join(strings.Fields(doc.Find("#acontent").Text()), ""); text != "12ad534" {
+		t.Fatalf("paragraphs should not be reordered: %v", text)
+	}
+}
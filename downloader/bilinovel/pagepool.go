@@ -0,0 +1,93 @@
+package bilinovel
+
+import (
+	"bilinovel-downloader/downloader"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// pagePool 浏览器标签页池，限制同时打开的标签页数量，标签页按需创建并在章节之间复用
+type pagePool struct {
+	newPage func() (playwright.Page, error)
+
+	// slots 中的每个元素代表一个可以使用的标签页名额
+	slots chan struct{}
+
+	mu    sync.Mutex
+	idle  []playwright.Page
+	pages []playwright.Page
+}
+
+func newPagePool(size int, newPage func() (playwright.Page, error)) *pagePool {
+	if size <= 0 {
+		size = 1
+	}
+	p := &pagePool{
+		newPage: newPage,
+		slots:   make(chan struct{}, size),
+	}
+	for range size {
+		p.slots <- struct{}{}
+	}
+	return p
+}
+
+// Get 获取一个空闲的标签页，没有空闲标签页且已达到上限时等待，直到 ctx 取消
+func (p *pagePool) Get(ctx context.Context) (playwright.Page, error) {
+	select {
+	case <-p.slots:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	p.mu.Lock()
+	for len(p.idle) > 0 {
+		page := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if !page.IsClosed() {
+			p.mu.Unlock()
+			return page, nil
+		}
+		// 空闲时被关闭的标签页不再由池管理
+		p.pages = slices.DeleteFunc(p.pages, func(pg playwright.Page) bool { return pg == page })
+	}
+	p.mu.Unlock()
+
+	page, err := p.newPage()
+	if err != nil {
+		p.slots <- struct{}{}
+		return nil, &downloader.BrowserError{Op: "create page", Err: err}
+	}
+	p.mu.Lock()
+	p.pages = append(p.pages, page)
+	p.mu.Unlock()
+	return page, nil
+}
+
+// Put 归还标签页，已经关闭的标签页（例如 ctx 取消时被关闭）会被丢弃，名额留给新的标签页
+func (p *pagePool) Put(page playwright.Page) {
+	p.mu.Lock()
+	if page.IsClosed() {
+		p.pages = slices.DeleteFunc(p.pages, func(pg playwright.Page) bool { return pg == page })
+	} else {
+		p.idle = append(p.idle, page)
+	}
+	p.mu.Unlock()
+	p.slots <- struct{}{}
+}
+
+// Close 关闭池中所有的标签页
+func (p *pagePool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, page := range p.pages {
+		if !page.IsClosed() {
+			_ = page.Close()
+		}
+	}
+	p.pages = nil
+	p.idle = nil
+}
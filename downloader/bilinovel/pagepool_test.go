@@ -0,0 +1,117 @@
+package bilinovel
+
+import (
+	"bilinovel-downloader/model"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// fakePage 只实现标签页池用到的方法
+type fakePage struct {
+	playwright.Page
+	closed bool
+}
+
+func (p *fakePage) IsClosed() bool { return p.closed }
+
+func (p *fakePage) Close(...playwright.PageCloseOptions) error {
+	p.closed = true
+	return nil
+}
+
+func TestPagePool(t *testing.T) {
+	created := 0
+	pool := newPagePool(2, func() (playwright.Page, error) {
+		created++
+		return &fakePage{}, nil
+	})
+	ctx := context.Background()
+
+	a, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatalf("failed to get page: %v", err)
+	}
+	b, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatalf("failed to get page: %v", err)
+	}
+
+	// 达到上限时等待，直到 ctx 取消
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Get(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	// 归还的标签页被复用
+	pool.Put(a)
+	if page, err := pool.Get(ctx); err != nil || page != a {
+		t.Fatalf("expected idle page to be reused, got %v, %v", page, err)
+	}
+
+	// 空闲时被关闭的标签页被丢弃，名额留给新的标签页
+	pool.Put(a)
+	pool.Put(b)
+	_ = b.Close()
+	_ = a.Close()
+	for range 2 {
+		page, err := pool.Get(ctx)
+		if err != nil {
+			t.Fatalf("failed to get page: %v", err)
+		}
+		if page.IsClosed() {
+			t.Fatalf("got closed page")
+		}
+	}
+	if created != 4 {
+		t.Fatalf("expected 4 pages to be created, got %v", created)
+	}
+	if len(pool.pages) != 2 {
+		t.Fatalf("closed pages should be removed from pool, got %v pages", len(pool.pages))
+	}
+
+	pool.Close()
+	if len(pool.pages) != 0 || len(pool.idle) != 0 {
+		t.Fatalf("pool should be empty after close")
+	}
+}
+
+func TestGetChaptersKeepsOrder(t *testing.T) {
+	b, err := New(BilinovelNewOption{Renderer: RendererNative, CacheDir: t.TempDir(), ImageDir: t.TempDir(), ChapterConcurrency: 8})
+	if err != nil {
+		t.Fatalf("failed to create downloader: %v", err)
+	}
+	defer b.Close()
+
+	// 章节从缓存读取，并发完成的顺序不确定
+	chapterIds := make([]int, 64)
+	for i := range chapterIds {
+		chapterIds[i] = 1000 - i
+		err := b.cache.Save(b.site.Name, &model.Chapter{
+			Id:       chapterIds[i],
+			NovelId:  1,
+			VolumeId: 2,
+			Title:    fmt.Sprint(chapterIds[i]),
+			Content:  &model.ChaperContent{},
+		})
+		if err != nil {
+			t.Fatalf("failed to save chapter: %v", err)
+		}
+	}
+
+	volume := &model.Volume{Id: 2, NovelId: 1, Chapters: make([]*model.Chapter, len(chapterIds))}
+	err = b.getChapters(context.Background(), volume, chapterIds)
+	if err != nil {
+		t.Fatalf("failed to get chapters: %v", err)
+	}
+	for i, chapter := range volume.Chapters {
+		if chapter == nil || chapter.Id != chapterIds[i] || chapter.Title != fmt.Sprint(chapterIds[i]) {
+			t.Fatalf("chapter %v out of order: %+v", i, chapter)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package bilinovel
+
+import (
+	"bilinovel-downloader/downloader"
+	"bilinovel-downloader/utils"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SiteDefinition 站点定义，描述页面的 URL 模板、选择器和清理规则。
+// 站点改版或者添加页面结构相同的站点时，只需要修改定义文件，不需要重新编译。
+//
+// URL 模板是相对于 BaseUrl 的路径，可以使用 {novelId}、{volumeId}、{chapterId} 和 {page} 占位符
+type SiteDefinition struct {
+	Name     string `json:"name" yaml:"name"`
+	BaseUrl  string `json:"baseUrl" yaml:"baseUrl"`
+	Language string `json:"language" yaml:"language"`
+	// Patterns 用于从 URL 解析小说和卷 ID 的正则，使用命名分组 novel 和 volume
+	Patterns []string `json:"patterns" yaml:"patterns"`
+
+	Urls      SiteUrls      `json:"urls" yaml:"urls"`
+	Selectors SiteSelectors `json:"selectors" yaml:"selectors"`
+
+	// NextPageMarker 章节页面中存在这段文本时说明章节还有下一页
+	NextPageMarker string `json:"nextPageMarker" yaml:"nextPageMarker"`
+	// Cleanup 需要从正文中移除的元素的选择器
+	Cleanup []string `json:"cleanup" yaml:"cleanup"`
+	// RateLimit 站点主机的请求速率限制，为空时使用命令行的默认配置
+	RateLimit *utils.RateLimit `json:"rateLimit,omitempty" yaml:"rateLimit,omitempty"`
+	// LoginMarkers 章节页面中出现这些文本时说明需要登录才能阅读
+	LoginMarkers []string `json:"loginMarkers,omitempty" yaml:"loginMarkers,omitempty"`
+	// Hosts 主机覆盖，主机名到地址的映射，用于绕过被污染的 DNS
+	Hosts map[string]string `json:"hosts,omitempty" yaml:"hosts,omitempty"`
+}
+
+type SiteUrls struct {
+	Novel       string `json:"novel" yaml:"novel"`
+	Catalog     string `json:"catalog" yaml:"catalog"`
+	Volume      string `json:"volume" yaml:"volume"`
+	Chapter     string `json:"chapter" yaml:"chapter"`
+	ChapterPage string `json:"chapterPage" yaml:"chapterPage"`
+}
+
+type SiteSelectors struct {
+	Title        string   `json:"title" yaml:"title"`
+	Description  string   `json:"description" yaml:"description"`
+	Authors      []string `json:"authors" yaml:"authors"`
+	Cover        string   `json:"cover" yaml:"cover"`
+	VolumeLink   string   `json:"volumeLink" yaml:"volumeLink"`
+	ChapterLink  string   `json:"chapterLink" yaml:"chapterLink"`
+	ChapterTitle string   `json:"chapterTitle" yaml:"chapterTitle"`
+	Content      string   `json:"content" yaml:"content"`
+}
+
+//go:embed sites.json
+var sitesJSON []byte
+
+var defaultSite *SiteDefinition
+
+func init() {
+	sites, err := ParseSiteDefinitions(sitesJSON)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded site definitions: %v", err))
+	}
+	RegisterSiteDefinitions(sites)
+	defaultSite = sites[0]
+}
+
+// DefaultSite 返回内置的 bilinovel 站点定义
+func DefaultSite() *SiteDefinition {
+	return defaultSite
+}
+
+// ParseSiteDefinitions 解析 JSON 数组格式的站点定义
+func ParseSiteDefinitions(data []byte) ([]*SiteDefinition, error) {
+	return parseSiteDefinitions(data, json.Unmarshal)
+}
+
+// ParseSiteDefinitionsYAML 解析 YAML 列表格式的站点定义，字段名与 JSON 相同
+func ParseSiteDefinitionsYAML(data []byte) ([]*SiteDefinition, error) {
+	return parseSiteDefinitions(data, yaml.Unmarshal)
+}
+
+func parseSiteDefinitions(data []byte, unmarshal func([]byte, any) error) ([]*SiteDefinition, error) {
+	sites := make([]*SiteDefinition, 0)
+	err := unmarshal(data, &sites)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse site definitions: %w", err)
+	}
+	if len(sites) == 0 {
+		return nil, fmt.Errorf("no site definition found")
+	}
+	for _, site := range sites {
+		err = site.validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid site definition %q: %w", site.Name, err)
+		}
+	}
+	return sites, nil
+}
+
+// RegisterSiteDefinitions 将站点定义注册到 downloader，同名站点会被覆盖
+func RegisterSiteDefinitions(sites []*SiteDefinition) {
+	for _, site := range sites {
+		patterns := make([]*regexp.Regexp, len(site.Patterns))
+		for i, pattern := range site.Patterns {
+			patterns[i] = regexp.MustCompile(pattern)
+		}
+		downloader.Register(downloader.Site{
+			Name:     site.Name,
+			Patterns: patterns,
+			New: func(option downloader.Options) (downloader.Downloader, error) {
+				return New(BilinovelNewOption{
+					Site:               site,
+					Concurrency:        option.Concurrency,
+					ChapterConcurrency: option.ChapterConcurrency,
+					MaxPages:           option.MaxPages,
+					Debug:              option.Debug,
+					Renderer:           Renderer(option.Renderer),
+					Timeout:            option.Timeout,
+					RenderTimeout:      option.RenderTimeout,
+					Progress:           option.Progress,
+					CacheDir:           option.CacheDir,
+					ImageDir:           option.ImageDir,
+					RateLimit:          option.RateLimit,
+					HostRateLimits:     option.HostRateLimits,
+					Hosts:              option.Hosts,
+					Resolver:           option.Resolver,
+					Proxy:              option.Proxy,
+					HostProxies:        option.HostProxies,
+					Cookies:            option.Cookies,
+				})
+			},
+		})
+	}
+}
+
+// LoadSiteDefinitions 从 JSON 或 YAML 文件加载站点定义并注册到 downloader，
+// 可以用来覆盖内置站点的选择器或者添加页面结构相同的站点
+func LoadSiteDefinitions(path string) ([]*SiteDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read site definitions: %w", err)
+	}
+	var sites []*SiteDefinition
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		sites, err = ParseSiteDefinitionsYAML(data)
+	default:
+		sites, err = ParseSiteDefinitions(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	RegisterSiteDefinitions(sites)
+	return sites, nil
+}
+
+func (s *SiteDefinition) validate() error {
+	required := map[string]string{
+		"name":                   s.Name,
+		"baseUrl":                s.BaseUrl,
+		"urls.novel":             s.Urls.Novel,
+		"urls.catalog":           s.Urls.Catalog,
+		"urls.volume":            s.Urls.Volume,
+		"urls.chapter":           s.Urls.Chapter,
+		"urls.chapterPage":       s.Urls.ChapterPage,
+		"selectors.title":        s.Selectors.Title,
+		"selectors.volumeLink":   s.Selectors.VolumeLink,
+		"selectors.chapterLink":  s.Selectors.ChapterLink,
+		"selectors.chapterTitle": s.Selectors.ChapterTitle,
+		"selectors.content":      s.Selectors.Content,
+	}
+	for field, value := range required {
+		if value == "" {
+			return fmt.Errorf("%v is required", field)
+		}
+	}
+	for _, pattern := range s.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	if !strings.Contains(s.Urls.Volume, "{volumeId}") {
+		return fmt.Errorf("urls.volume must contain {volumeId}")
+	}
+	if !strings.Contains(s.Urls.Chapter, "{chapterId}") {
+		return fmt.Errorf("urls.chapter must contain {chapterId}")
+	}
+	s.BaseUrl = strings.TrimSuffix(s.BaseUrl, "/")
+	if s.Language == "" {
+		s.Language = "zh-CN"
+	}
+	return nil
+}
+
+// path 展开 URL 模板，返回相对于站点的路径
+func (s *SiteDefinition) path(template string, novelId, volumeId, chapterId, page int) string {
+	return strings.NewReplacer(
+		"{novelId}", strconv.Itoa(novelId),
+		"{volumeId}", strconv.Itoa(volumeId),
+		"{chapterId}", strconv.Itoa(chapterId),
+		"{page}", strconv.Itoa(page),
+	).Replace(template)
+}
+
+// url 展开 URL 模板，返回完整地址
+func (s *SiteDefinition) url(template string, novelId, volumeId, chapterId, page int) string {
+	return s.BaseUrl + s.path(template, novelId, volumeId, chapterId, page)
+}
+
+// absUrl 将页面中的相对链接转换为完整地址
+func (s *SiteDefinition) absUrl(link string) string {
+	if strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://") {
+		return link
+	}
+	return s.BaseUrl + link
+}
+
+// host 返回站点的主机名
+func (s *SiteDefinition) host() string {
+	u, err := url.Parse(s.BaseUrl)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// templateRegexp 将 URL 模板转换为正则，capture 对应的占位符作为唯一的子匹配，其余 ID 占位符匹配任意数字
+func (s *SiteDefinition) templateRegexp(template string, novelId int, capture string) *regexp.Regexp {
+	pattern := regexp.QuoteMeta(template)
+	for _, placeholder := range []string{"{novelId}", "{volumeId}", "{chapterId}", "{page}"} {
+		quoted := regexp.QuoteMeta(placeholder)
+		switch {
+		case placeholder == capture:
+			pattern = strings.Replace(pattern, quoted, `(\d+)`, 1)
+		case placeholder == "{novelId}" && novelId != 0:
+			pattern = strings.ReplaceAll(pattern, quoted, strconv.Itoa(novelId))
+		}
+		pattern = strings.ReplaceAll(pattern, quoted, `\d+`)
+	}
+	return regexp.MustCompile(pattern)
+}
+
+func (s *SiteDefinition) acceptLanguage() string {
+	if s.Language == "zh-TW" {
+		return "zh-TW,zh;q=0.9,en-GB;q=0.8,en;q=0.7,zh-CN;q=0.6"
+	}
+	return "zh-CN,zh;q=0.9,en-GB;q=0.8,en;q=0.7,zh-TW;q=0.6"
+}
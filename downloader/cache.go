@@ -0,0 +1,77 @@
+package downloader
+
+import (
+	"bilinovel-downloader/model"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ChapterCache 按章节持久化已下载的内容，中断后重新下载时跳过已经完成的章节。
+// 每个章节保存为 <dir>/<site>/<novelId>/<volumeId>/<chapterId>.json，包含所有页的正文和图片，
+// 镜像站的小说、卷和章节 ID 可能相同，按站点分开保存
+type ChapterCache struct {
+	dir string
+}
+
+func NewChapterCache(dir string) *ChapterCache {
+	return &ChapterCache{dir: dir}
+}
+
+func (c *ChapterCache) path(site string, novelId int, volumeId int, chapterId int) string {
+	return filepath.Join(c.dir, site, fmt.Sprint(novelId), fmt.Sprint(volumeId), fmt.Sprintf("%d.json", chapterId))
+}
+
+// Load 读取缓存的章节，不存在或者无法解析时返回 false
+func (c *ChapterCache) Load(site string, novelId int, volumeId int, chapterId int) (*model.Chapter, bool) {
+	data, err := os.ReadFile(c.path(site, novelId, volumeId, chapterId))
+	if err != nil {
+		return nil, false
+	}
+	chapter := &model.Chapter{}
+	err = json.Unmarshal(data, chapter)
+	if err != nil || chapter.Content == nil {
+		return nil, false
+	}
+	return chapter, true
+}
+
+// Save 保存章节，先写入临时文件再重命名，避免中断时留下不完整的文件
+func (c *ChapterCache) Save(site string, chapter *model.Chapter) error {
+	path := c.path(site, chapter.NovelId, chapter.VolumeId, chapter.Id)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	data, err := json.Marshal(chapter)
+	if err != nil {
+		return fmt.Errorf("failed to encode chapter: %w", err)
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(path), "chapter-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.Write(data)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write chapter cache: %w", err)
+	}
+	err = os.Rename(tempFile.Name(), path)
+	if err != nil {
+		return fmt.Errorf("failed to write chapter cache: %w", err)
+	}
+	return nil
+}
+
+// Remove 删除缓存的章节，章节内容在站点上更新后需要重新下载时使用
+func (c *ChapterCache) Remove(site string, novelId int, volumeId int, chapterId int) error {
+	err := os.Remove(c.path(site, novelId, volumeId, chapterId))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove chapter cache: %w", err)
+	}
+	return nil
+}
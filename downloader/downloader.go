@@ -1,11 +1,19 @@
 package downloader
 
-import "bilinovel-downloader/model"
+import (
+	"bilinovel-downloader/model"
+	"context"
+)
 
+// ChapterFilter 选择卷中要下载的章节，index 为章节在目录中从 1 开始的序号，为空时下载所有章节
+type ChapterFilter func(index int, chapter *model.Chapter) bool
+
+// Downloader 站点下载器，所有网络操作都会在 ctx 取消或超时后尽快返回。
+// GetNovel 中有卷获取失败时返回获取成功的卷和 *PartialError
 type Downloader interface {
-	GetNovel(novelId int, skipChapterContent bool, skipVolumes []int) (*model.Novel, error)
-	GetVolume(novelId int, volumeId int, skipChapterContent bool) (*model.Volume, error)
-	GetChapter(novelId int, volumeId int, chapterId int) (*model.Chapter, error)
+	GetNovel(ctx context.Context, novelId int, skipChapterContent bool, skipVolumes []int) (*model.Novel, error)
+	GetVolume(ctx context.Context, novelId int, volumeId int, skipChapterContent bool, chapters ChapterFilter) (*model.Volume, error)
+	GetChapter(ctx context.Context, novelId int, volumeId int, chapterId int) (*model.Chapter, error)
 	GetStyleCSS() string
 	GetExtraFiles() []model.ExtraFile
 	Close() error
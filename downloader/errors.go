@@ -0,0 +1,158 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 下载失败的分类，使用 errors.Is 判断，具体的信息可以通过 errors.As 取得 StatusError、ParseError 和 BrowserError
+var (
+	// ErrNotFound 小说、卷或者章节不存在
+	ErrNotFound = errors.New("not found")
+	// ErrRateLimited 请求被站点限流，重试多次后仍然失败
+	ErrRateLimited = errors.New("rate limited")
+	// ErrChallenge 站点返回了反爬验证页面（例如 Cloudflare 的人机验证）
+	ErrChallenge = errors.New("anti-bot challenge")
+	// ErrParse 页面结构与站点定义不符，通常是站点改版，需要更新选择器
+	ErrParse = errors.New("failed to parse page")
+	// ErrBrowser 浏览器启动或者渲染页面失败
+	ErrBrowser = errors.New("browser failure")
+	// ErrLoginRequired 章节需要登录才能阅读，但没有导入 cookie
+	ErrLoginRequired = errors.New("login required")
+	// ErrSessionExpired 导入的 cookie 已经失效，站点要求重新登录
+	ErrSessionExpired = errors.New("session expired")
+)
+
+// StatusError 请求返回了非 200 的状态码
+type StatusError struct {
+	Url        string
+	StatusCode int
+	// Challenge 响应是反爬验证页面
+	Challenge bool
+}
+
+func (e *StatusError) Error() string {
+	if e.Challenge {
+		return fmt.Sprintf("%v: anti-bot challenge (%d %v)", e.Url, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%v: %d %v", e.Url, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrChallenge:
+		return e.Challenge
+	case ErrNotFound:
+		return !e.Challenge && (e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone)
+	case ErrRateLimited:
+		return !e.Challenge && (e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable)
+	}
+	return false
+}
+
+// challengeMarkers 反爬验证页面中的特征文本
+var challengeMarkers = []string{
+	"cf-chl-",
+	"Just a moment...",
+	"Attention Required! | Cloudflare",
+	"cf_captcha_kind",
+}
+
+// CheckResponse 检查响应，状态码不是 200 时返回 StatusError，反爬验证页面的 Challenge 为 true
+func CheckResponse(url string, statusCode int, header http.Header, body []byte) error {
+	challenge := header.Get("cf-mitigated") == "challenge"
+	if statusCode == http.StatusOK && !challenge {
+		return nil
+	}
+	if !challenge && (statusCode == http.StatusForbidden || statusCode == http.StatusServiceUnavailable) {
+		page := string(body)
+		for _, marker := range challengeMarkers {
+			if strings.Contains(page, marker) {
+				challenge = true
+				break
+			}
+		}
+	}
+	return &StatusError{Url: url, StatusCode: statusCode, Challenge: challenge}
+}
+
+// ParseError 页面中找不到站点定义要求的元素或者数据
+type ParseError struct {
+	Url      string
+	Selector string
+	Reason   string
+}
+
+func (e *ParseError) Error() string {
+	message := e.Reason
+	if e.Selector != "" {
+		message += ": " + e.Selector
+	}
+	if e.Url != "" {
+		message = e.Url + ": " + message
+	}
+	return message
+}
+
+func (e *ParseError) Is(target error) bool {
+	return target == ErrParse
+}
+
+// BrowserError 浏览器操作失败，Op 为失败的操作
+type BrowserError struct {
+	Op  string
+	Err error
+}
+
+func (e *BrowserError) Error() string {
+	return fmt.Sprintf("browser %v: %v", e.Op, e.Err)
+}
+
+func (e *BrowserError) Unwrap() error {
+	return e.Err
+}
+
+func (e *BrowserError) Is(target error) bool {
+	return target == ErrBrowser
+}
+
+// VolumeError 获取某一卷失败
+type VolumeError struct {
+	VolumeId int
+	// SeriesIdx 卷在目录中从 1 开始的序号
+	SeriesIdx int
+	Err       error
+}
+
+func (e *VolumeError) Error() string {
+	return fmt.Sprintf("volume %d: %v", e.VolumeId, e.Err)
+}
+
+func (e *VolumeError) Unwrap() error {
+	return e.Err
+}
+
+// PartialError 小说中有卷获取失败，GetNovel 会同时返回获取成功的卷。
+// errors.Is 会检查每一卷失败的原因
+type PartialError struct {
+	NovelId int
+	Volumes []*VolumeError
+}
+
+func (e *PartialError) Error() string {
+	messages := make([]string, len(e.Volumes))
+	for i, volume := range e.Volumes {
+		messages[i] = volume.Error()
+	}
+	return fmt.Sprintf("novel %d: %d volumes failed: %v", e.NovelId, len(e.Volumes), strings.Join(messages, "; "))
+}
+
+func (e *PartialError) Unwrap() []error {
+	errs := make([]error, len(e.Volumes))
+	for i, volume := range e.Volumes {
+		errs[i] = volume
+	}
+	return errs
+}
@@ -0,0 +1,28 @@
+package linovelib
+
+import (
+	"bilinovel-downloader/downloader/bilinovel"
+	_ "embed"
+	"fmt"
+)
+
+//go:embed sites.json
+var sitesJSON []byte
+
+var (
+	// Site 简体中文站点
+	Site *bilinovel.SiteDefinition
+	// TwSite 繁体中文站点
+	TwSite *bilinovel.SiteDefinition
+)
+
+// init 注册 linovelib 的站点定义。linovelib 是 bilinovel 的镜像站，页面结构和反爬策略相同，
+// 下载直接使用 bilinovel 的分页、字体反混淆和诱饵段落移除流程
+func init() {
+	sites, err := bilinovel.ParseSiteDefinitions(sitesJSON)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded site definitions: %v", err))
+	}
+	bilinovel.RegisterSiteDefinitions(sites)
+	Site, TwSite = sites[0], sites[1]
+}
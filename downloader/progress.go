@@ -0,0 +1,53 @@
+package downloader
+
+import "time"
+
+// EventType 进度事件类型
+type EventType string
+
+const (
+	// EventNovelDiscovered 获取到小说的卷列表，Total 为需要下载的卷数
+	EventNovelDiscovered EventType = "novel_discovered"
+	// EventVolumeStarted 开始下载卷的章节，Total 为章节数
+	EventVolumeStarted EventType = "volume_started"
+	// EventChapterPageFetched 获取并处理完章节的一页，Page 为页码
+	EventChapterPageFetched EventType = "chapter_page_fetched"
+	// EventChapterFinished 章节的所有页都已获取
+	EventChapterFinished EventType = "chapter_finished"
+	// EventImageFetched 获取到一张图片，Url 为图片地址
+	EventImageFetched EventType = "image_fetched"
+	// EventVolumeFinished 卷的所有章节都已获取
+	EventVolumeFinished EventType = "volume_finished"
+	// EventVolumePacked 卷已打包为 EPUB 或文本，由调用方在打包完成后发出
+	EventVolumePacked EventType = "volume_packed"
+	// EventFailed 卷、章节或图片获取失败，Err 为失败原因
+	EventFailed EventType = "failed"
+)
+
+// Event 下载进度事件，未涉及的字段为零值
+type Event struct {
+	Type      EventType
+	Time      time.Time
+	NovelId   int
+	VolumeId  int
+	ChapterId int
+	Title     string
+	Page      int
+	Total     int
+	Url       string
+	Err       error
+}
+
+// ProgressFunc 进度回调，可能在多个 goroutine 中同时被调用，实现需要保证并发安全且不能阻塞
+type ProgressFunc func(event Event)
+
+// Emit 补全事件时间后调用回调，回调为空时忽略
+func (f ProgressFunc) Emit(event Event) {
+	if f == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	f(event)
+}
@@ -0,0 +1,132 @@
+package downloader
+
+import (
+	"bilinovel-downloader/utils"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Options 创建下载器的通用参数，由命令行传给各个站点适配器
+type Options struct {
+	Concurrency int
+	// ChapterConcurrency 每卷同时下载的章节数
+	ChapterConcurrency int
+	// MaxPages 同时打开的浏览器标签页上限
+	MaxPages int
+	Debug    bool
+	Renderer string
+	// Timeout 单次网络请求的超时时间，为 0 时不超时
+	Timeout time.Duration
+	// RenderTimeout 渲染章节页面的超时时间，为 0 时使用站点的默认值
+	RenderTimeout time.Duration
+	// CacheDir 章节缓存目录，为空时不缓存
+	CacheDir string
+	// ImageDir 图片库目录，为空时使用临时目录
+	ImageDir string
+	// Progress 进度回调，为空时不发出进度事件
+	Progress ProgressFunc
+	// RateLimit 每个主机默认的请求速率限制，Rate 为 0 时不限制
+	RateLimit utils.RateLimit
+	// HostRateLimits 单独配置的主机的请求速率限制，优先于站点定义中的配置
+	HostRateLimits map[string]utils.RateLimit
+	// Hosts 主机覆盖，主机名到地址（可以带端口）的映射，优先于站点定义中的配置
+	Hosts map[string]string
+	// Resolver 没有主机覆盖的主机使用的解析器，为空时使用系统解析
+	Resolver utils.Resolver
+	// Proxy 默认代理，为空时使用环境变量中的代理
+	Proxy string
+	// HostProxies 主机（以点开头时为域名后缀）单独使用的代理，值为 utils.ProxyDirect 时直接连接
+	HostProxies map[string]string
+	// Cookies 导入的登录会话，请求和浏览器共用，为空时匿名访问
+	Cookies []*http.Cookie
+}
+
+// Site 站点适配器，Patterns 中的正则通过命名分组 novel 和 volume 提取小说和卷的 ID
+type Site struct {
+	Name     string
+	Patterns []*regexp.Regexp
+	New      func(option Options) (Downloader, error)
+}
+
+// Target 从 URL 中解析出的下载目标，VolumeId 为 0 表示下载整本小说
+type Target struct {
+	Site     *Site
+	NovelId  int
+	VolumeId int
+}
+
+var (
+	sites   []*Site
+	sitesMu sync.RWMutex
+)
+
+// Register 注册站点适配器，通常在适配器包的 init 中调用
+func Register(site Site) {
+	sitesMu.Lock()
+	defer sitesMu.Unlock()
+	for i, s := range sites {
+		if s.Name == site.Name {
+			sites[i] = &site
+			return
+		}
+	}
+	sites = append(sites, &site)
+}
+
+// Lookup 按名称查找站点适配器
+func Lookup(name string) (*Site, bool) {
+	sitesMu.RLock()
+	defer sitesMu.RUnlock()
+	for _, s := range sites {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// Sites 返回所有已注册的站点适配器
+func Sites() []*Site {
+	sitesMu.RLock()
+	defer sitesMu.RUnlock()
+	return append([]*Site(nil), sites...)
+}
+
+// Resolve 找到能处理该 URL 的站点适配器并解析出小说和卷的 ID
+func Resolve(rawUrl string) (*Target, error) {
+	sitesMu.RLock()
+	defer sitesMu.RUnlock()
+	for _, site := range sites {
+		for _, pattern := range site.Patterns {
+			matches := pattern.FindStringSubmatch(rawUrl)
+			if matches == nil {
+				continue
+			}
+			target := &Target{Site: site}
+			for i, name := range pattern.SubexpNames() {
+				if matches[i] == "" {
+					continue
+				}
+				var err error
+				switch name {
+				case "novel":
+					target.NovelId, err = strconv.Atoi(matches[i])
+				case "volume":
+					target.VolumeId, err = strconv.Atoi(matches[i])
+				}
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse id from url %v: %w", rawUrl, err)
+				}
+			}
+			if target.NovelId == 0 {
+				return nil, fmt.Errorf("novel id not found in url: %v", rawUrl)
+			}
+			return target, nil
+		}
+	}
+	return nil, fmt.Errorf("no downloader registered for url: %v", rawUrl)
+}
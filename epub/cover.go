@@ -0,0 +1,221 @@
+package epub
+
+import (
+	"bilinovel-downloader/fonts"
+	"bilinovel-downloader/imageproc"
+	"bilinovel-downloader/imagestore"
+	"bilinovel-downloader/model"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	generatedCoverWidth  = 1200
+	generatedCoverHeight = 1600
+	generatedCoverMargin = 120
+	// 标题最多的行数，超过时缩小字号
+	generatedCoverTitleLines = 5
+)
+
+var (
+	coverBackground = color.RGBA{0x2b, 0x3a, 0x55, 0xff}
+	coverFrame      = color.RGBA{0xd8, 0xc3, 0x8f, 0xff}
+	coverText       = color.RGBA{0xf5, 0xf1, 0xe8, 0xff}
+)
+
+var coverFont = sync.OnceValues(func() (*sfnt.Font, error) {
+	return opentype.Parse(fonts.MiLanting)
+})
+
+// writeCover 将封面写入 outputPath，返回文件名和图片尺寸。
+// 没有封面（下载封面失败）或者封面无法解码时，根据标题和作者生成封面
+func writeCover(images *imagestore.Store, profile *imageproc.Profile, volume *model.Volume, outputPath string) (string, int, int, error) {
+	if volume.CoverImage != "" {
+		coverPath, err := writeImage(images, profile, volume.CoverImage, filepath.Join(outputPath, coverFilename(volume)))
+		if err != nil {
+			return "", 0, 0, err
+		}
+		data, err := os.ReadFile(coverPath)
+		if err != nil {
+			return "", 0, 0, fmt.Errorf("failed to read cover: %w", err)
+		}
+		width, height, err := imageproc.Size(data)
+		if err == nil {
+			return filepath.Base(coverPath), width, height, nil
+		}
+		slog.Warn("Failed to decode cover, generating one", slog.String("cover", volume.CoverImage), slog.Any("error", err))
+		err = os.Remove(coverPath)
+		if err != nil {
+			return "", 0, 0, fmt.Errorf("failed to remove cover: %w", err)
+		}
+	}
+
+	data, err := generateCover(volume.Title, volume.Authors)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if profile != nil {
+		data, _, err = imageproc.Process(data, *profile)
+		if err != nil {
+			return "", 0, 0, fmt.Errorf("failed to process cover: %w", err)
+		}
+	}
+	width, height, err := imageproc.Size(data)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	coverPath := withImageExt(filepath.Join(outputPath, "cover.png"), data)
+	err = os.WriteFile(coverPath, data, 0644)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("failed to write cover: %w", err)
+	}
+	return filepath.Base(coverPath), width, height, nil
+}
+
+// generateCover 使用内置字体绘制只有标题和作者的 PNG 封面，字体缺少字形的文字不绘制，避免出现方框
+func generateCover(title string, authors []string) ([]byte, error) {
+	f, err := coverFont()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cover font: %w", err)
+	}
+	title = strings.TrimSpace(title)
+	if !hasGlyphs(f, title) {
+		slog.Warn("Cover font lacks glyphs of title, generating cover without title", slog.String("title", title))
+		title = ""
+	}
+	author := strings.Join(authors, " / ")
+	if !hasGlyphs(f, author) {
+		slog.Warn("Cover font lacks glyphs of authors, generating cover without authors", slog.String("authors", author))
+		author = ""
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, generatedCoverWidth, generatedCoverHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(coverBackground), image.Point{}, draw.Src)
+	frame := image.Rect(generatedCoverMargin/2, generatedCoverMargin/2, generatedCoverWidth-generatedCoverMargin/2, generatedCoverHeight-generatedCoverMargin/2)
+	drawBorder(img, frame, 6, coverFrame)
+
+	maxWidth := generatedCoverWidth - 2*generatedCoverMargin
+	if title != "" {
+		titleFace, titleLines, err := fitText(f, title, maxWidth, 96, 48)
+		if err != nil {
+			return nil, err
+		}
+		defer titleFace.Close()
+		drawLines(img, titleFace, titleLines, generatedCoverHeight*2/5)
+	}
+
+	if author != "" {
+		authorFace, authorLines, err := fitText(f, author, maxWidth, 48, 48)
+		if err != nil {
+			return nil, err
+		}
+		defer authorFace.Close()
+		drawLines(img, authorFace, authorLines, generatedCoverHeight*4/5)
+	}
+
+	buf := bytes.Buffer{}
+	err = png.Encode(&buf, img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode cover: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// hasGlyphs 判断字体是否包含文字中所有非空白字符的字形
+func hasGlyphs(f *sfnt.Font, text string) bool {
+	var buf sfnt.Buffer
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		index, err := f.GlyphIndex(&buf, r)
+		if err != nil || index == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// fitText 从 size 开始逐步缩小字号，直到文字换行后不超过 generatedCoverTitleLines 行或者达到 minSize
+func fitText(f *sfnt.Font, text string, maxWidth int, size float64, minSize float64) (font.Face, []string, error) {
+	for {
+		face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create font face: %w", err)
+		}
+		lines := wrapText(face, text, fixed.I(maxWidth))
+		if len(lines) <= generatedCoverTitleLines || size <= minSize {
+			return face, lines, nil
+		}
+		face.Close()
+		size = max(size-8, minSize)
+	}
+}
+
+// wrapText 按字符换行，中文没有空格分词，逐字累计宽度
+func wrapText(face font.Face, text string, maxWidth fixed.Int26_6) []string {
+	lines := make([]string, 0)
+	line := strings.Builder{}
+	width := fixed.Int26_6(0)
+	prev := rune(0)
+	for _, r := range text {
+		if r == '\n' {
+			lines = append(lines, line.String())
+			line.Reset()
+			width = 0
+			continue
+		}
+		advance, _ := face.GlyphAdvance(r)
+		if line.Len() > 0 && width+face.Kern(prev, r)+advance > maxWidth {
+			lines = append(lines, line.String())
+			line.Reset()
+			width = 0
+		} else if line.Len() > 0 {
+			width += face.Kern(prev, r)
+		}
+		line.WriteRune(r)
+		width += advance
+		prev = r
+	}
+	if line.Len() > 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+// drawLines 以 centerY 为中心垂直排列各行文字，每行水平居中
+func drawLines(img *image.RGBA, face font.Face, lines []string, centerY int) {
+	metrics := face.Metrics()
+	lineHeight := metrics.Height.Ceil() * 5 / 4
+	y := centerY - lineHeight*len(lines)/2 + metrics.Ascent.Ceil()
+	drawer := &font.Drawer{Dst: img, Src: image.NewUniform(coverText), Face: face}
+	for _, line := range lines {
+		width := drawer.MeasureString(line).Ceil()
+		drawer.Dot = fixed.P((generatedCoverWidth-width)/2, y)
+		drawer.DrawString(line)
+		y += lineHeight
+	}
+}
+
+func drawBorder(img *image.RGBA, rect image.Rectangle, thickness int, c color.Color) {
+	src := image.NewUniform(c)
+	draw.Draw(img, image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y+thickness), src, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(rect.Min.X, rect.Max.Y-thickness, rect.Max.X, rect.Max.Y), src, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(rect.Min.X, rect.Min.Y, rect.Min.X+thickness, rect.Max.Y), src, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(rect.Max.X-thickness, rect.Min.Y, rect.Max.X, rect.Max.Y), src, image.Point{}, draw.Src)
+}
@@ -0,0 +1,75 @@
+package epub
+
+import (
+	"bilinovel-downloader/imageproc"
+	"bilinovel-downloader/imagestore"
+	"bilinovel-downloader/model"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeImage 将图片库中的图片写入 dst，配置了图片处理时先按配置处理。
+// 文件的扩展名根据图片内容修正，返回实际写入的路径
+func writeImage(images *imagestore.Store, profile *imageproc.Profile, name string, dst string) (string, error) {
+	data, err := images.Get(name)
+	if err != nil {
+		return "", err
+	}
+	if profile != nil {
+		data, _, err = imageproc.Process(data, *profile)
+		if err != nil {
+			return "", fmt.Errorf("failed to process image %v: %w", name, err)
+		}
+	}
+	dst = withImageExt(dst, data)
+
+	if profile == nil {
+		return dst, images.Link(name, dst)
+	}
+	err = os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return "", fmt.Errorf("failed to create image directory: %w", err)
+	}
+	err = os.WriteFile(dst, data, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to write image: %w", err)
+	}
+	return dst, nil
+}
+
+// withImageExt 将文件扩展名替换为与图片内容一致的扩展名，
+// 地址没有扩展名或者带有查询参数时文件名中的扩展名是不可靠的
+func withImageExt(filename string, data []byte) string {
+	_, ext := imageproc.Sniff(data)
+	if ext == "" {
+		return filename
+	}
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ext
+}
+
+// coverFilename 返回封面在 EPUB 中的文件名
+func coverFilename(volume *model.Volume) string {
+	ext := filepath.Ext(volume.CoverImage)
+	if volume.CoverImage == "" {
+		ext = filepath.Ext(volume.CoverUrl)
+	}
+	return "cover" + ext
+}
+
+// cloneForPack 复制卷和章节内容，打包时修改图片文件名不会影响调用方的卷
+func cloneForPack(volume *model.Volume) *model.Volume {
+	clone := *volume
+	clone.Chapters = make([]*model.Chapter, len(volume.Chapters))
+	for i, chapter := range volume.Chapters {
+		c := *chapter
+		if chapter.Content != nil {
+			content := *chapter.Content
+			content.ImageNames = append([]string(nil), chapter.Content.ImageNames...)
+			c.Content = &content
+		}
+		clone.Chapters[i] = &c
+	}
+	return &clone
+}
@@ -2,6 +2,8 @@ package epub
 
 import (
 	"archive/zip"
+	"bilinovel-downloader/imageproc"
+	"bilinovel-downloader/imagestore"
 	"bilinovel-downloader/model"
 	"bilinovel-downloader/template"
 	"bilinovel-downloader/utils"
@@ -18,7 +20,21 @@ import (
 	"github.com/google/uuid"
 )
 
-func PackVolumeToEpub(volume *model.Volume, outputPath string, styleCSS string, extraFiles []model.ExtraFile) error {
+// DefaultLanguage 未指定语言的卷使用的语言
+const DefaultLanguage = "zh-CN"
+
+func volumeLanguage(volume *model.Volume) string {
+	if volume.Language == "" {
+		return DefaultLanguage
+	}
+	return volume.Language
+}
+
+// PackVolumeToEpub 将卷打包为 EPUB，封面和插图从图片库中读取，
+// profile 不为空时按配置处理图片（格式转换、缩小、灰度等），以适配电子阅读器
+func PackVolumeToEpub(volume *model.Volume, outputPath string, styleCSS string, extraFiles []model.ExtraFile, images *imagestore.Store, profile *imageproc.Profile) error {
+	volume = cloneForPack(volume)
+	lang := volumeLanguage(volume)
 	outputPath = filepath.Join(outputPath, utils.CleanDirName(volume.Title))
 	_, err := os.Stat(outputPath)
 	if err != nil {
@@ -44,18 +60,16 @@ func PackVolumeToEpub(volume *model.Volume, outputPath string, styleCSS string,
 	// 将文字写入 OEBPS/Text/chapter-%03v.xhtml
 	// 将图片写入 OEBPS/Images/chapter-%03v/
 	for i, chapter := range volume.Chapters {
-		imageNames := make([]string, 0)
-		for imgName, imgData := range chapter.Content.Images {
-			imageNames = append(imageNames, imgName)
+		text := chapter.Content.Html
+		for j, imgName := range chapter.Content.ImageNames {
 			imgPath := filepath.Join(outputPath, fmt.Sprintf("OEBPS/Images/chapter-%03v/%s", i, imgName))
-			err := os.MkdirAll(filepath.Dir(imgPath), 0755)
-			if err != nil {
-				return fmt.Errorf("failed to create image directory: %v", err)
-			}
-			err = os.WriteFile(imgPath, imgData, 0644)
+			imgPath, err := writeImage(images, profile, imgName, imgPath)
 			if err != nil {
 				return fmt.Errorf("failed to write image: %v", err)
 			}
+			// 处理后的图片扩展名可能变化，正文和清单使用实际的文件名
+			chapter.Content.ImageNames[j] = filepath.Base(imgPath)
+			text = strings.ReplaceAll(text, imgName, fmt.Sprintf("../Images/chapter-%03v/%s", i, filepath.Base(imgPath)))
 		}
 		chapterPath := filepath.Join(outputPath, fmt.Sprintf("OEBPS/Text/chapter-%03v.xhtml", i))
 		err = os.MkdirAll(filepath.Dir(chapterPath), 0755)
@@ -67,22 +81,18 @@ func PackVolumeToEpub(volume *model.Volume, outputPath string, styleCSS string,
 			return fmt.Errorf("failed to create chapter file: %v", err)
 		}
 		defer file.Close()
-		text := chapter.Content.Html
-		for _, imgName := range imageNames {
-			text = strings.ReplaceAll(text, imgName, fmt.Sprintf("../Images/chapter-%03v/%s", i, imgName))
-		}
-		err = template.ContentXHTML(chapter.Title, text).Render(context.Background(), file)
+		err = template.ContentXHTML(lang, chapter.Title, text).Render(context.Background(), file)
 		if err != nil {
 			return fmt.Errorf("failed to write chapter: %v", err)
 		}
 	}
 
 	// 将 Cover 写入
-	coverPath := filepath.Join(outputPath, fmt.Sprintf("cover%s", filepath.Ext(volume.CoverUrl)))
-	err = os.WriteFile(coverPath, volume.Cover, 0644)
+	coverName, coverWidth, coverHeight, err := writeCover(images, profile, volume, outputPath)
 	if err != nil {
 		return fmt.Errorf("failed to write cover: %v", err)
 	}
+	volume.CoverImage = coverName
 
 	// 将 CoverXHTML 写入 OEBPS/Text/cover.xhtml
 	coverXHTMLPath := filepath.Join(outputPath, "OEBPS/Text/cover.xhtml")
@@ -91,7 +101,7 @@ func PackVolumeToEpub(volume *model.Volume, outputPath string, styleCSS string,
 		return fmt.Errorf("failed to create cover XHTML file: %v", err)
 	}
 	defer file.Close()
-	err = template.CoverXHTML(fmt.Sprintf("../../%s", filepath.Base(coverPath))).Render(context.Background(), file)
+	err = template.CoverXHTML(lang, fmt.Sprintf("../../%s", coverName), coverWidth, coverHeight).Render(context.Background(), file)
 	if err != nil {
 		return fmt.Errorf("failed to render cover XHTML: %v", err)
 	}
@@ -111,7 +121,7 @@ func PackVolumeToEpub(volume *model.Volume, outputPath string, styleCSS string,
 	}
 	contents.WriteString(`</ol>`)
 	contents.WriteString(`</nav>`)
-	err = template.ContentXHTML("目录", contents.String()).Render(context.Background(), file)
+	err = template.ContentXHTML(lang, "目录", contents.String()).Render(context.Background(), file)
 	if err != nil {
 		return fmt.Errorf("failed to render contents XHTML: %v", err)
 	}
@@ -185,7 +195,7 @@ func CreateContentOPF(outputPath string, uuid string, volume *model.Volume, extr
 		},
 		Languages: []model.DCLanguage{
 			{
-				Value: "zh-CN",
+				Value: volumeLanguage(volume),
 			},
 		},
 		Descriptions: []model.DCDescription{
@@ -229,8 +239,8 @@ func CreateContentOPF(outputPath string, uuid string, volume *model.Volume, extr
 	})
 	manifest.Items = append(manifest.Items, model.ManifestItem{
 		ID:         "cover",
-		Link:       fmt.Sprintf("cover%s", filepath.Ext(volume.CoverUrl)),
-		Media:      fmt.Sprintf("image/%s", strings.ReplaceAll(strings.TrimPrefix(filepath.Ext(volume.CoverUrl), "."), "jpg", "jpeg")),
+		Link:       coverFilename(volume),
+		Media:      imageproc.MediaType(filepath.Ext(coverFilename(volume))),
 		Properties: "cover-image",
 	})
 	for i, chapter := range volume.Chapters {
@@ -239,11 +249,11 @@ func CreateContentOPF(outputPath string, uuid string, volume *model.Volume, extr
 			Link:  fmt.Sprintf("OEBPS/Text/chapter-%03v.xhtml", i),
 			Media: "application/xhtml+xml",
 		})
-		for filename := range chapter.Content.Images {
+		for _, filename := range chapter.Content.ImageNames {
 			item := model.ManifestItem{
 				ID:    fmt.Sprintf("chapter-%03v-%s", i, filepath.Base(filename)),
 				Link:  fmt.Sprintf("OEBPS/Images/chapter-%03v/%s", i, filepath.Base(filename)),
-				Media: fmt.Sprintf("image/%s", strings.ReplaceAll(strings.TrimPrefix(filepath.Ext(filename), "."), "jpg", "jpeg")),
+				Media: imageproc.MediaType(filepath.Ext(filename)),
 			}
 			manifest.Items = append(manifest.Items, item)
 		}
@@ -0,0 +1,11 @@
+package fonts
+
+import (
+	_ "embed"
+)
+
+// MiLanting 小米兰亭字体，用于还原 bilinovel 混淆的字形，也用于绘制生成的封面。
+// 字体包含 CJK 统一汉字基本区的全部字形，其他字符在绘制封面前检查
+//
+//go:embed "MI LANTING.ttf"
+var MiLanting []byte
@@ -0,0 +1,173 @@
+package imageproc
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"sort"
+
+	_ "image/gif"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Profile 图片处理配置，用于生成适合电子阅读器的 EPUB
+type Profile struct {
+	Name string
+	// MaxWidth 和 MaxHeight 为图片的最大尺寸，超过时等比缩小，为 0 时不限制
+	MaxWidth  int
+	MaxHeight int
+	// Quality JPEG 质量，为 0 时使用 jpeg.DefaultQuality
+	Quality int
+	// Grayscale 转换为灰度图，适用于墨水屏
+	Grayscale bool
+	// ForceJPEG 将 PNG 也转换为 JPEG，WebP 总是会转换为 JPEG
+	ForceJPEG bool
+	// StripMetadata 重新编码图片以去掉 EXIF 等元数据，没有元数据的图片不重新编码
+	StripMetadata bool
+}
+
+// Profiles 内置的设备配置，尺寸为设备屏幕的分辨率
+var Profiles = map[string]Profile{
+	"kindle":            {MaxWidth: 1072, MaxHeight: 1448, Quality: 75, Grayscale: true, StripMetadata: true},
+	"kindle-pw5":        {MaxWidth: 1236, MaxHeight: 1648, Quality: 75, Grayscale: true, StripMetadata: true},
+	"kindle-oasis":      {MaxWidth: 1264, MaxHeight: 1680, Quality: 75, Grayscale: true, StripMetadata: true},
+	"kindle-scribe":     {MaxWidth: 1860, MaxHeight: 2480, Quality: 80, Grayscale: true, StripMetadata: true},
+	"kobo-clara":        {MaxWidth: 1072, MaxHeight: 1448, Quality: 75, Grayscale: true, StripMetadata: true},
+	"kobo-libra":        {MaxWidth: 1264, MaxHeight: 1680, Quality: 75, Grayscale: true, StripMetadata: true},
+	"kobo-libra-colour": {MaxWidth: 1264, MaxHeight: 1680, Quality: 80, StripMetadata: true},
+	"tablet":            {MaxWidth: 1600, MaxHeight: 2560, Quality: 85, StripMetadata: true},
+}
+
+func init() {
+	for name, profile := range Profiles {
+		profile.Name = name
+		Profiles[name] = profile
+	}
+}
+
+// Lookup 按名称查找设备配置
+func Lookup(name string) (Profile, error) {
+	profile, ok := Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown device profile: %v, available: %v", name, ProfileNames())
+	}
+	return profile, nil
+}
+
+// ProfileNames 返回所有设备配置的名称
+func ProfileNames() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Process 按配置处理图片，返回处理后的数据和对应的扩展名（带点）。
+// 不需要处理的图片和无法解码的图片原样返回，扩展名为空表示沿用原扩展名，GIF 可能是动图，不做处理
+func Process(data []byte, profile Profile) ([]byte, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || format == "gif" {
+		return data, "", nil
+	}
+
+	toJPEG := format == "webp" || (profile.ForceJPEG && format == "png")
+	resize := exceeds(config.Width, config.Height, profile)
+	strip := profile.StripMetadata && hasMetadata(data, format)
+	if !toJPEG && !resize && !profile.Grayscale && !strip {
+		return data, "", nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	if resize {
+		img = scale(img, profile)
+	}
+	// 灰度图和 JPEG 没有透明通道，透明的部分按白色背景合成
+	keepPNG := format == "png" && !toJPEG
+	if profile.Grayscale || !keepPNG {
+		img = flatten(img)
+	}
+	if profile.Grayscale {
+		img = grayscale(img)
+	}
+
+	buf := bytes.Buffer{}
+	switch {
+	case keepPNG:
+		err = png.Encode(&buf, img)
+		if err == nil {
+			return buf.Bytes(), ".png", nil
+		}
+	default:
+		quality := profile.Quality
+		if quality <= 0 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+		if err == nil {
+			return buf.Bytes(), ".jpg", nil
+		}
+	}
+	return nil, "", fmt.Errorf("failed to encode image: %w", err)
+}
+
+// Size 返回图片的宽和高，只解析图片头部，不解码像素
+func Size(data []byte) (int, int, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to decode image config: %w", err)
+	}
+	return config.Width, config.Height, nil
+}
+
+func exceeds(width int, height int, profile Profile) bool {
+	return (profile.MaxWidth > 0 && width > profile.MaxWidth) || (profile.MaxHeight > 0 && height > profile.MaxHeight)
+}
+
+// scale 等比缩小到不超过最大尺寸
+func scale(img image.Image, profile Profile) image.Image {
+	bounds := img.Bounds()
+	ratio := 1.0
+	if profile.MaxWidth > 0 {
+		ratio = min(ratio, float64(profile.MaxWidth)/float64(bounds.Dx()))
+	}
+	if profile.MaxHeight > 0 {
+		ratio = min(ratio, float64(profile.MaxHeight)/float64(bounds.Dy()))
+	}
+	width := max(int(float64(bounds.Dx())*ratio), 1)
+	height := max(int(float64(bounds.Dy())*ratio), 1)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// flatten 将有透明通道的图片合成到白色背景上
+func flatten(img image.Image) image.Image {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return img
+	}
+	bounds := img.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Over)
+	return dst
+}
+
+func grayscale(img image.Image) image.Image {
+	if gray, ok := img.(*image.Gray); ok {
+		return gray
+	}
+	bounds := img.Bounds()
+	dst := image.NewGray(bounds)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Src)
+	return dst
+}
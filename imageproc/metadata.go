@@ -0,0 +1,65 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// hasMetadata 判断图片中是否有需要去除的元数据，只检查 JPEG 和 PNG，无法解析时视为有元数据
+func hasMetadata(data []byte, format string) bool {
+	switch format {
+	case "jpeg":
+		return jpegHasMetadata(data)
+	case "png":
+		return pngHasMetadata(data)
+	}
+	return true
+}
+
+// jpegHasMetadata 检查 EXIF/XMP（APP1）、IPTC（APP13）和注释段，扫描到图像数据为止
+func jpegHasMetadata(data []byte) bool {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return true
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return true
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xff:
+			// 段之间的填充字节
+			i++
+			continue
+		case marker == 0xda || marker == 0xd9:
+			return false
+		case marker == 0xe1 || marker == 0xed || marker == 0xfe:
+			return true
+		}
+		i += 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+	}
+	return true
+}
+
+var pngMetadataChunks = [][]byte{[]byte("tEXt"), []byte("zTXt"), []byte("iTXt"), []byte("eXIf"), []byte("tIME")}
+
+// pngHasMetadata 检查文本、EXIF 和时间块
+func pngHasMetadata(data []byte) bool {
+	if len(data) < 8 || !bytes.Equal(data[:8], []byte("\x89PNG\r\n\x1a\n")) {
+		return true
+	}
+	for i := 8; i+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		chunk := data[i+4 : i+8]
+		for _, t := range pngMetadataChunks {
+			if bytes.Equal(chunk, t) {
+				return true
+			}
+		}
+		if bytes.Equal(chunk, []byte("IEND")) {
+			return false
+		}
+		i += 12 + length
+	}
+	return true
+}
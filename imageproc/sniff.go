@@ -0,0 +1,53 @@
+package imageproc
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+var extByMediaType = map[string]string{
+	"image/jpeg":    ".jpg",
+	"image/png":     ".png",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/bmp":     ".bmp",
+	"image/avif":    ".avif",
+	"image/svg+xml": ".svg",
+}
+
+var mediaTypeByExt = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+	".avif": "image/avif",
+	".svg":  "image/svg+xml",
+}
+
+// Sniff 根据图片内容判断媒体类型和对应的扩展名（带点），无法识别时返回空字符串
+func Sniff(data []byte) (string, string) {
+	mediaType := http.DetectContentType(data)
+	if _, ok := extByMediaType[mediaType]; !ok {
+		switch {
+		// http.DetectContentType 不识别 AVIF 和 SVG
+		case len(data) >= 12 && string(data[4:8]) == "ftyp" && (string(data[8:12]) == "avif" || string(data[8:12]) == "avis"):
+			mediaType = "image/avif"
+		case bytes.Contains(data[:min(len(data), 1024)], []byte("<svg")):
+			mediaType = "image/svg+xml"
+		default:
+			return "", ""
+		}
+	}
+	return mediaType, extByMediaType[mediaType]
+}
+
+// MediaType 根据扩展名返回媒体类型，扩展名应当已经通过 Sniff 修正
+func MediaType(ext string) string {
+	if mediaType, ok := mediaTypeByExt[strings.ToLower(ext)]; ok {
+		return mediaType
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,154 @@
+package imagestore
+
+import (
+	"bilinovel-downloader/model"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+)
+
+// Store 在多卷之间和多次运行之间共享的图片目录，已保存的图片不会重新下载。
+// 图片以 Name 根据图片地址计算出的文件名保存在 <dir>/<前两位>/<文件名>
+type Store struct {
+	dir string
+}
+
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Name 返回图片地址对应的文件名，为地址的 sha256 加上地址路径中的扩展名。
+// 扩展名只用于辨认，打包时会根据图片内容修正
+func Name(imgUrl string) string {
+	hash := sha256.Sum256([]byte(imgUrl))
+	ext := path.Ext(imgUrl)
+	if u, err := url.Parse(imgUrl); err == nil {
+		ext = path.Ext(u.Path)
+	}
+	if !extRegexp.MatchString(ext) {
+		ext = ""
+	}
+	return fmt.Sprintf("%x%s", string(hash[:]), ext)
+}
+
+var extRegexp = regexp.MustCompile(`^\.[A-Za-z0-9]{1,5}$`)
+
+// Path 返回图片在目录中的路径
+func (s *Store) Path(name string) string {
+	name = filepath.Base(name)
+	prefix := name
+	if len(prefix) > 2 {
+		prefix = prefix[:2]
+	}
+	return filepath.Join(s.dir, prefix, name)
+}
+
+// Has 判断图片是否已经保存
+func (s *Store) Has(name string) bool {
+	info, err := os.Stat(s.Path(name))
+	return err == nil && info.Size() > 0
+}
+
+// Get 读取图片
+func (s *Store) Get(name string) ([]byte, error) {
+	data, err := os.ReadFile(s.Path(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image %v: %w", name, err)
+	}
+	return data, nil
+}
+
+// Put 保存图片，先写入临时文件再重命名，避免中断时留下不完整的图片
+func (s *Store) Put(name string, data []byte) error {
+	imgPath := s.Path(name)
+	err := os.MkdirAll(filepath.Dir(imgPath), 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create image directory: %w", err)
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(imgPath), "image-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.Write(data)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	err = os.Rename(tempFile.Name(), imgPath)
+	if err != nil {
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	return nil
+}
+
+// Link 将图片放到 dst，优先使用硬链接避免重复占用空间，无法链接时复制
+func (s *Store) Link(name string, dst string) error {
+	err := os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create image directory: %w", err)
+	}
+	_ = os.Remove(dst)
+	if os.Link(s.Path(name), dst) == nil {
+		return nil
+	}
+
+	src, err := os.Open(s.Path(name))
+	if err != nil {
+		return fmt.Errorf("failed to read image %v: %w", name, err)
+	}
+	defer src.Close()
+	file, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create image file: %w", err)
+	}
+	defer file.Close()
+	_, err = io.Copy(file, src)
+	if err != nil {
+		return fmt.Errorf("failed to copy image: %w", err)
+	}
+	return nil
+}
+
+// MigrateChapter 将旧版本缓存中内嵌的图片数据移动到图片库，章节只保留图片文件名
+func (s *Store) MigrateChapter(chapter *model.Chapter) error {
+	if chapter.Content == nil || len(chapter.Content.Images) == 0 {
+		return nil
+	}
+	for name, data := range chapter.Content.Images {
+		err := s.Put(name, data)
+		if err != nil {
+			return err
+		}
+		chapter.Content.AddImage(name)
+	}
+	chapter.Content.Images = nil
+	return nil
+}
+
+// MigrateVolume 将旧版本缓存中内嵌的封面和图片数据移动到图片库
+func (s *Store) MigrateVolume(volume *model.Volume) error {
+	if len(volume.Cover) > 0 {
+		name := Name(volume.CoverUrl)
+		err := s.Put(name, volume.Cover)
+		if err != nil {
+			return err
+		}
+		volume.CoverImage = name
+		volume.Cover = nil
+	}
+	for _, chapter := range volume.Chapters {
+		err := s.MigrateChapter(chapter)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
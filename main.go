@@ -2,8 +2,9 @@ package main
 
 import (
 	"bilinovel-downloader/cmd"
+	"os"
 )
 
 func main() {
-	_ = cmd.RootCmd.Execute()
+	os.Exit(cmd.Execute())
 }
@@ -1,8 +1,20 @@
 package model
 
+import "slices"
+
 type ChaperContent struct {
-	Html   string
-	Images map[string][]byte
+	Html string
+	// ImageNames 正文引用的图片在图片库中的文件名
+	ImageNames []string `json:",omitempty"`
+	// Deprecated: 旧版本缓存中内嵌的图片数据，读取后迁移到图片库
+	Images map[string][]byte `json:",omitempty"`
+}
+
+// AddImage 记录正文引用的图片，重复的文件名只记录一次
+func (c *ChaperContent) AddImage(name string) {
+	if !slices.Contains(c.ImageNames, name) {
+		c.ImageNames = append(c.ImageNames, name)
+	}
 }
 
 type Chapter struct {
@@ -15,17 +27,20 @@ type Chapter struct {
 }
 
 type Volume struct {
-	Id          int
-	SeriesIdx   int
-	Title       string
-	Url         string
-	CoverUrl    string
-	Cover       []byte
+	Id         int
+	SeriesIdx  int
+	Title      string
+	Url        string
+	CoverUrl   string
+	CoverImage string
+	// Deprecated: 旧版本缓存中内嵌的封面数据，读取后迁移到图片库
+	Cover       []byte `json:",omitempty"`
 	Description string
 	Authors     []string
 	Chapters    []*Chapter
 	NovelId     int
 	NovelTitle  string
+	Language    string
 }
 
 type Novel struct {
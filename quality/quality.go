@@ -0,0 +1,174 @@
+package quality
+
+import (
+	"bilinovel-downloader/model"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// IssueKind 章节内容问题的类型
+type IssueKind string
+
+const (
+	// IssuePUA 正文中残留私用区字符，通常是混淆字体没有还原
+	IssuePUA IssueKind = "pua"
+	// IssueShort 正文过短，可能只下载到了部分页面
+	IssueShort IssueKind = "short"
+	// IssueParagraphs 段落数明显少于同卷其他章节
+	IssueParagraphs IssueKind = "paragraphs"
+	// IssueMissing 目录中的章节没有内容
+	IssueMissing IssueKind = "missing"
+)
+
+// Thresholds 判断章节内容异常的阈值
+type Thresholds struct {
+	// MaxPUARatio 私用区字符占正文字符的比例上限，为 0 时出现任何私用区字符都视为异常
+	MaxPUARatio float64
+	// MinChars 没有插图的章节正文字符数下限
+	MinChars int
+	// MinParagraphRatio 章节段落数与同卷章节段落数中位数之比的下限
+	MinParagraphRatio float64
+}
+
+// DefaultThresholds 默认的阈值
+var DefaultThresholds = Thresholds{
+	MaxPUARatio:       0,
+	MinChars:          100,
+	MinParagraphRatio: 0.2,
+}
+
+// Issue 一个章节的内容问题
+type Issue struct {
+	Kind      IssueKind
+	ChapterId int
+	Title     string
+	Detail    string
+}
+
+// Report 一卷的质量报告
+type Report struct {
+	NovelId  int
+	VolumeId int
+	Title    string
+	Chapters int
+	Issues   []Issue
+}
+
+// OK 判断卷中是否没有发现问题
+func (r *Report) OK() bool {
+	return len(r.Issues) == 0
+}
+
+func (r *Report) String() string {
+	if r.OK() {
+		return fmt.Sprintf("%v (%v): %v chapters ok", r.Title, r.VolumeId, r.Chapters)
+	}
+	builder := strings.Builder{}
+	fmt.Fprintf(&builder, "%v (%v): %v issues in %v chapters", r.Title, r.VolumeId, len(r.Issues), r.Chapters)
+	for _, issue := range r.Issues {
+		fmt.Fprintf(&builder, "\n  [%v] chapter %v %v: %v", issue.Kind, issue.ChapterId, issue.Title, issue.Detail)
+	}
+	return builder.String()
+}
+
+// stats 章节正文的统计
+type stats struct {
+	chars      int
+	pua        int
+	paragraphs int
+	images     int
+}
+
+// Check 检查卷中每个章节的正文，标记残留的私用区字符、过短的章节、段落数异常和目录中没有内容的章节
+func Check(volume *model.Volume, thresholds Thresholds) *Report {
+	report := &Report{
+		NovelId:  volume.NovelId,
+		VolumeId: volume.Id,
+		Title:    volume.Title,
+		Chapters: len(volume.Chapters),
+	}
+
+	chapterStats := make([]*stats, len(volume.Chapters))
+	paragraphs := make([]int, 0, len(volume.Chapters))
+	for i, chapter := range volume.Chapters {
+		if chapter.Content == nil || strings.TrimSpace(chapter.Content.Html) == "" {
+			report.add(IssueMissing, chapter, "no content")
+			continue
+		}
+		s, err := countContent(chapter.Content.Html)
+		if err != nil {
+			report.add(IssueMissing, chapter, err.Error())
+			continue
+		}
+		s.images = max(s.images, len(chapter.Content.ImageNames))
+		chapterStats[i] = s
+		if s.images == 0 {
+			paragraphs = append(paragraphs, s.paragraphs)
+		}
+	}
+	median := 0
+	if len(paragraphs) > 0 {
+		slices.Sort(paragraphs)
+		median = paragraphs[len(paragraphs)/2]
+	}
+
+	for i, chapter := range volume.Chapters {
+		s := chapterStats[i]
+		if s == nil {
+			continue
+		}
+		if s.pua > 0 && float64(s.pua) > thresholds.MaxPUARatio*float64(s.chars) {
+			report.add(IssuePUA, chapter, fmt.Sprintf("%v of %v characters are in private use area", s.pua, s.chars))
+		}
+		// 插图页通常只有很少的文字
+		if s.images > 0 {
+			continue
+		}
+		if s.chars < thresholds.MinChars {
+			report.add(IssueShort, chapter, fmt.Sprintf("%v characters", s.chars))
+		}
+		if median > 0 && float64(s.paragraphs) < thresholds.MinParagraphRatio*float64(median) {
+			report.add(IssueParagraphs, chapter, fmt.Sprintf("%v paragraphs, median of volume is %v", s.paragraphs, median))
+		}
+	}
+	return report
+}
+
+func (r *Report) add(kind IssueKind, chapter *model.Chapter, detail string) {
+	r.Issues = append(r.Issues, Issue{
+		Kind:      kind,
+		ChapterId: chapter.Id,
+		Title:     chapter.Title,
+		Detail:    detail,
+	})
+}
+
+func countContent(html string) (*stats, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse content: %w", err)
+	}
+	s := &stats{
+		paragraphs: doc.Find("p").Length(),
+		images:     doc.Find("img").Length(),
+	}
+	for _, r := range doc.Text() {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		s.chars++
+		if IsPUA(r) {
+			s.pua++
+		}
+	}
+	return s, nil
+}
+
+// IsPUA 判断字符是否在 Unicode 私用区，包括基本多文种平面和两个补充私用区平面
+func IsPUA(r rune) bool {
+	return (r >= 0xE000 && r <= 0xF8FF) || (r >= 0xF0000 && r <= 0xFFFFD) || (r >= 0x100000 && r <= 0x10FFFD)
+}
@@ -0,0 +1,179 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Load 读取导出的 cookie 文件，根据内容判断是 JSON 格式（浏览器扩展或者 Playwright 导出）还是 Netscape cookies.txt 格式
+func Load(path string) ([]*http.Cookie, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cookies: %w", err)
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return ParseJSON(trimmed)
+	}
+	return ParseNetscape(bytes.NewReader(data))
+}
+
+// ParseNetscape 解析 Netscape cookies.txt 格式，每行为制表符分隔的
+// domain、includeSubdomains、path、secure、expires、name、value，#HttpOnly_ 前缀表示 HttpOnly
+func ParseNetscape(r io.Reader) ([]*http.Cookie, error) {
+	cookies := make([]*http.Cookie, 0)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(text, "#HttpOnly_"); ok {
+			text, httpOnly = rest, true
+		}
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("invalid cookies line %d: expected 7 fields, got %d", line, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cookies line %d: invalid expires: %w", line, err)
+		}
+		cookie := &http.Cookie{
+			Domain:   fields[0],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		if expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+		cookies = append(cookies, cookie)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cookies: %w", err)
+	}
+	return cookies, nil
+}
+
+// jsonCookie 兼容 Cookie-Editor、EditThisCookie 等浏览器扩展和 Playwright storage state 导出的字段
+type jsonCookie struct {
+	Name           string   `json:"name"`
+	Value          string   `json:"value"`
+	Domain         string   `json:"domain"`
+	Path           string   `json:"path"`
+	Secure         bool     `json:"secure"`
+	HttpOnly       bool     `json:"httpOnly"`
+	SameSite       string   `json:"sameSite"`
+	ExpirationDate *float64 `json:"expirationDate"`
+	Expires        *float64 `json:"expires"`
+}
+
+// ParseJSON 解析 JSON 格式的 cookie，可以是 cookie 数组，也可以是带有 cookies 字段的 Playwright storage state
+func ParseJSON(data []byte) ([]*http.Cookie, error) {
+	var items []jsonCookie
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		state := struct {
+			Cookies []jsonCookie `json:"cookies"`
+		}{}
+		err := json.Unmarshal(data, &state)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse cookies: %w", err)
+		}
+		items = state.Cookies
+	} else {
+		err := json.Unmarshal(data, &items)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse cookies: %w", err)
+		}
+	}
+
+	cookies := make([]*http.Cookie, 0, len(items))
+	for _, item := range items {
+		if item.Name == "" || item.Domain == "" {
+			return nil, fmt.Errorf("failed to parse cookies: cookie without name or domain")
+		}
+		cookie := &http.Cookie{
+			Name:     item.Name,
+			Value:    item.Value,
+			Domain:   item.Domain,
+			Path:     item.Path,
+			Secure:   item.Secure,
+			HttpOnly: item.HttpOnly,
+			SameSite: parseSameSite(item.SameSite),
+		}
+		expires := item.ExpirationDate
+		if expires == nil {
+			expires = item.Expires
+		}
+		// Playwright 使用 -1 表示会话 cookie
+		if expires != nil && *expires > 0 {
+			sec, frac := math.Modf(*expires)
+			cookie.Expires = time.Unix(int64(sec), int64(frac*1e9))
+		}
+		cookies = append(cookies, cookie)
+	}
+	return cookies, nil
+}
+
+func parseSameSite(s string) http.SameSite {
+	switch strings.ToLower(s) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "lax":
+		return http.SameSiteLaxMode
+	case "none", "no_restriction":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteDefaultMode
+	}
+}
+
+// Expired 返回已经过期的 cookie，会话 cookie 不会过期
+func Expired(cookies []*http.Cookie, now time.Time) []*http.Cookie {
+	expired := make([]*http.Cookie, 0)
+	for _, cookie := range cookies {
+		if !cookie.Expires.IsZero() && cookie.Expires.Before(now) {
+			expired = append(expired, cookie)
+		}
+	}
+	return expired
+}
+
+// NewJar 创建包含 cookies 的 cookie jar，请求过程中服务器设置的 cookie 也会保存在其中
+func NewJar(cookies []*http.Cookie) (http.CookieJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+	}
+	for _, cookie := range cookies {
+		host := strings.TrimPrefix(cookie.Domain, ".")
+		path := cookie.Path
+		if path == "" {
+			path = "/"
+		}
+		c := *cookie
+		// 不带点的域名表示只对该主机生效，cookiejar 中对应没有 Domain 属性的 cookie
+		if !strings.HasPrefix(cookie.Domain, ".") {
+			c.Domain = ""
+		}
+		jar.SetCookies(&url.URL{Scheme: "https", Host: host, Path: path}, []*http.Cookie{&c})
+	}
+	return jar, nil
+}
@@ -2,6 +2,7 @@ package test
 
 import (
 	"bilinovel-downloader/downloader/bilinovel"
+	"context"
 	"encoding/json"
 	"fmt"
 	"testing"
@@ -13,7 +14,7 @@ func TestBilinovel_GetNovel(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to create bilinovel: %v", err)
 	}
-	novel, err := bilinovel.GetNovel(2727, false, nil)
+	novel, err := bilinovel.GetNovel(context.Background(), 2727, false, nil)
 	if err != nil {
 		t.Fatalf("failed to get novel: %v", err)
 	}
@@ -30,7 +31,7 @@ func TestBilinovel_GetVolume(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to create bilinovel: %v", err)
 	}
-	volume, err := bilinovel.GetVolume(2727, 129092, false)
+	volume, err := bilinovel.GetVolume(context.Background(), 2727, 129092, false, nil)
 	if err != nil {
 		t.Fatalf("failed to get volume: %v", err)
 	}
@@ -47,7 +48,7 @@ func TestBilinovel_GetChapter(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to create bilinovel: %v", err)
 	}
-	chapter, err := bilinovel.GetChapter(2727, 129092, 129094)
+	chapter, err := bilinovel.GetChapter(context.Background(), 2727, 129092, 129094)
 	if err != nil {
 		t.Fatalf("failed to get chapter: %v", err)
 	}
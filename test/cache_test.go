@@ -0,0 +1,74 @@
+package test
+
+import (
+	"bilinovel-downloader/downloader"
+	"bilinovel-downloader/model"
+	"testing"
+)
+
+func TestChapterCache(t *testing.T) {
+	cache := downloader.NewChapterCache(t.TempDir())
+	if _, ok := cache.Load("bilinovel", 1, 2, 3); ok {
+		t.Fatalf("expected cache miss")
+	}
+
+	chapter := &model.Chapter{
+		Id:       3,
+		NovelId:  1,
+		VolumeId: 2,
+		Title:    "第一章",
+		Content: &model.ChaperContent{
+			Html:       "<p>正文</p>",
+			ImageNames: []string{"a.jpg"},
+		},
+	}
+	err := cache.Save("bilinovel", chapter)
+	if err != nil {
+		t.Fatalf("failed to save chapter: %v", err)
+	}
+
+	cached, ok := cache.Load("bilinovel", 1, 2, 3)
+	if !ok {
+		t.Fatalf("expected cache hit")
+	}
+	if cached.Title != chapter.Title || cached.Content.Html != chapter.Content.Html || len(cached.Content.ImageNames) != 1 {
+		t.Fatalf("unexpected cached chapter: %+v", cached)
+	}
+}
+
+func TestChapterCacheSites(t *testing.T) {
+	// 镜像站的 ID 相同，不同站点的缓存互不影响
+	cache := downloader.NewChapterCache(t.TempDir())
+	for _, site := range []string{"linovelib", "linovelib-tw"} {
+		err := cache.Save(site, &model.Chapter{
+			Id:       3,
+			NovelId:  1,
+			VolumeId: 2,
+			Title:    site,
+			Content:  &model.ChaperContent{Html: "<p>" + site + "</p>"},
+		})
+		if err != nil {
+			t.Fatalf("failed to save chapter: %v", err)
+		}
+	}
+	for _, site := range []string{"linovelib", "linovelib-tw"} {
+		cached, ok := cache.Load(site, 1, 2, 3)
+		if !ok || cached.Title != site {
+			t.Fatalf("unexpected cached chapter of %v: %+v", site, cached)
+		}
+	}
+	if _, ok := cache.Load("bilinovel", 1, 2, 3); ok {
+		t.Fatalf("expected cache miss for another site")
+	}
+
+	err := cache.Remove("linovelib", 1, 2, 3)
+	if err != nil {
+		t.Fatalf("failed to remove chapter: %v", err)
+	}
+	if _, ok := cache.Load("linovelib", 1, 2, 3); ok {
+		t.Fatalf("expected cache miss after remove")
+	}
+	if _, ok := cache.Load("linovelib-tw", 1, 2, 3); !ok {
+		t.Fatalf("removing one site should not affect another")
+	}
+}
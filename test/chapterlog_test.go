@@ -0,0 +1,53 @@
+package test
+
+import (
+	"bilinovel-downloader/downloader/bilinovel"
+	"testing"
+)
+
+func TestExtractUnscrambleParams(t *testing.T) {
+	// 反混淆后的 chapterlog.js 片段
+	script := `
+var chapterId = parseInt(ReadParams.chapterid);
+var elements = [];
+document.querySelectorAll("#acontent p").forEach(function (p) {
+	if (p.innerText.trim().length > 0) elements.push(p);
+});
+var j = elements.length;
+if (j > 20) {
+	var ms = chapterId * 127 + 235;
+	var value = [];
+	for (var i = 0; i < j - 20; i++) value.push(i);
+	for (var i = value.length - 1; i > 0; i--) {
+		ms = (ms * 9302 + 49397) % 233280;
+		var prop = Math.floor(ms / 233280 * (i + 1));
+		var tmp = value[i]; value[i] = value[prop]; value[prop] = tmp;
+	}
+	var aProperties = [];
+	for (var i = 0; i < 20; i++) aProperties.push(i);
+	for (var i = 0; i < value.length; i++) aProperties.push(value[i] + 20);
+}`
+
+	params, err := bilinovel.ExtractUnscrambleParams(script)
+	if err != nil {
+		t.Fatalf("failed to extract params: %v", err)
+	}
+	if *params != bilinovel.DefaultUnscrambleParams {
+		t.Fatalf("unexpected params: %+v", *params)
+	}
+
+	// 混淆器常用十六进制字面量
+	hexScript := `var _0x1=_0x2*0x7f+0xeb;if(_0x3<=0x14)return;_0x4[_0x5]+0x14;_0x1=(_0x1*0x2456+0xc0f5)%0x38f40;`
+	params, err = bilinovel.ExtractUnscrambleParams(hexScript)
+	if err != nil {
+		t.Fatalf("failed to extract params: %v", err)
+	}
+	if *params != bilinovel.DefaultUnscrambleParams {
+		t.Fatalf("unexpected params: %+v", *params)
+	}
+
+	_, err = bilinovel.ExtractUnscrambleParams(`console.log("no algorithm here")`)
+	if err == nil {
+		t.Fatalf("expected error for script without algorithm")
+	}
+}
@@ -0,0 +1,38 @@
+package test
+
+import (
+	"bilinovel-downloader/cmd"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestExecuteExitCode(t *testing.T) {
+	cmd.RootCmd.SetOut(io.Discard)
+	cmd.RootCmd.SetErr(io.Discard)
+	defer cmd.RootCmd.SetArgs(nil)
+
+	// cobra 的参数值在多次执行之间保留，每个下载用例都显式指定相关参数
+	download := func(args ...string) []string {
+		return append([]string{"download", "-o", t.TempDir(), "--renderer", "native", "-n", "2388"}, args...)
+	}
+	cases := []struct {
+		args []string
+		code int
+	}{
+		// 用法错误
+		{[]string{"pack", "--unknown-flag"}, 2},
+		{[]string{"unknown-command"}, 2},
+		{download("--site", "bilinovel", "--volumes", "x-y", "--chapters="), 2},
+		{download("--site", "bilinovel", "--volumes=", "--chapters", "1-3"), 2},
+		{download("--site", "unknown", "--volumes=", "--chapters="), 2},
+		// 命令运行中的失败按原因分类
+		{[]string{"pack", "-d", filepath.Join(t.TempDir(), "missing")}, 1},
+	}
+	for _, c := range cases {
+		cmd.RootCmd.SetArgs(c.args)
+		if code := cmd.Execute(); code != c.code {
+			t.Errorf("unexpected exit code for %v: %v, want %v", c.args, code, c.code)
+		}
+	}
+}
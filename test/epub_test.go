@@ -0,0 +1,151 @@
+package test
+
+import (
+	"archive/zip"
+	"bilinovel-downloader/epub"
+	"bilinovel-downloader/imagestore"
+	"bilinovel-downloader/model"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// packTestVolume 打包只有一章的卷，返回 EPUB 中的文件
+func packTestVolume(t *testing.T, store *imagestore.Store, title string, authors []string, coverImage string) map[string]string {
+	volume := &model.Volume{
+		Id:         84522,
+		Title:      title,
+		CoverImage: coverImage,
+		Authors:    authors,
+		Chapters: []*model.Chapter{
+			{Id: 1, Title: "第一章", Content: &model.ChaperContent{Html: "<p>正文</p>"}},
+		},
+	}
+	outputPath := t.TempDir()
+	err := epub.PackVolumeToEpub(volume, outputPath, "", nil, store, nil)
+	if err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+
+	reader, err := zip.OpenReader(filepath.Join(outputPath, volume.Title+".epub"))
+	if err != nil {
+		t.Fatalf("failed to open epub: %v", err)
+	}
+	defer reader.Close()
+	files := make(map[string]string)
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("failed to open %v: %v", file.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read %v: %v", file.Name, err)
+		}
+		files[filepath.ToSlash(file.Name)] = string(data)
+	}
+	return files
+}
+
+// checkCover 检查封面页的 viewBox 和图片尺寸与封面一致，封面文件存在且在清单中，返回封面图片
+func checkCover(t *testing.T, files map[string]string, width int, height int) image.Image {
+	coverXHTML, ok := files["OEBPS/Text/cover.xhtml"]
+	if !ok {
+		t.Fatalf("cover page not found")
+	}
+	if !strings.Contains(coverXHTML, fmt.Sprintf(`viewBox="0 0 %d %d"`, width, height)) {
+		t.Fatalf("unexpected cover viewBox: %v", coverXHTML)
+	}
+	if !strings.Contains(coverXHTML, fmt.Sprintf(`width="%d" height="%d"`, width, height)) {
+		t.Fatalf("unexpected cover size: %v", coverXHTML)
+	}
+	start := strings.Index(coverXHTML, `xlink:href="../../`)
+	if start < 0 {
+		t.Fatalf("cover image not referenced: %v", coverXHTML)
+	}
+	coverName := coverXHTML[start+len(`xlink:href="../../`):]
+	coverName = coverName[:strings.IndexByte(coverName, '"')]
+	cover, ok := files[coverName]
+	if !ok {
+		t.Fatalf("cover image %v not found", coverName)
+	}
+	img, _, err := image.Decode(strings.NewReader(cover))
+	if err != nil || img.Bounds().Dx() != width || img.Bounds().Dy() != height {
+		t.Fatalf("unexpected cover image: %v %v", img, err)
+	}
+	if !strings.Contains(files["content.opf"], coverName) {
+		t.Fatalf("cover image not in manifest")
+	}
+	return img
+}
+
+// hasCoverText 判断生成的封面在 rect 范围内是否绘制了文字
+func hasCoverText(img image.Image, rect image.Rectangle) bool {
+	text := color.RGBA{0xf5, 0xf1, 0xe8, 0xff}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if color.RGBAModel.Convert(img.At(x, y)) == text {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestPackVolumeCover(t *testing.T) {
+	store := imagestore.New(t.TempDir())
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 640, 480)))
+	if err != nil {
+		t.Fatalf("failed to encode cover: %v", err)
+	}
+	coverName := imagestore.Name("https://img.example.com/cover.png")
+	err = store.Put(coverName, buf.Bytes())
+	if err != nil {
+		t.Fatalf("failed to put cover: %v", err)
+	}
+
+	// 生成的封面中标题在上半部分，作者在下半部分
+	titleArea := image.Rect(0, 0, 1200, 900)
+	authorArea := image.Rect(0, 1100, 1200, 1500)
+	cases := []struct {
+		name    string
+		title   string
+		authors []string
+		cover   string
+		width   int
+		height  int
+		// 生成的封面中是否有标题和作者
+		drawTitle, drawAuthor bool
+	}{
+		// 封面页使用封面的实际尺寸，不再固定为 400x581
+		{name: "cover size", title: "测试卷", authors: []string{"作者"}, cover: coverName, width: 640, height: 480},
+		// 没有封面时根据标题和作者生成 1200x1600 的封面
+		{name: "without cover", title: "测试卷", authors: []string{"作者"}, width: 1200, height: 1600, drawTitle: true, drawAuthor: true},
+		{name: "cjk title", title: "魔法禁书目录 第二十二卷 「とある」", authors: []string{"鎌池和馬", "灰村キヨタカ"}, width: 1200, height: 1600, drawTitle: true, drawAuthor: true},
+		// 字体缺少字形的文字不绘制
+		{name: "missing glyphs", title: "测试卷\U0001F389", authors: []string{"作者"}, width: 1200, height: 1600, drawAuthor: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			files := packTestVolume(t, store, c.title, c.authors, c.cover)
+			img := checkCover(t, files, c.width, c.height)
+			if c.cover != "" {
+				return
+			}
+			if got := hasCoverText(img, titleArea); got != c.drawTitle {
+				t.Fatalf("expected title drawn: %v, got %v", c.drawTitle, got)
+			}
+			if got := hasCoverText(img, authorArea); got != c.drawAuthor {
+				t.Fatalf("expected authors drawn: %v, got %v", c.drawAuthor, got)
+			}
+		})
+	}
+}
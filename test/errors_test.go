@@ -0,0 +1,75 @@
+package test
+
+import (
+	"bilinovel-downloader/downloader"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCheckResponse(t *testing.T) {
+	cases := []struct {
+		status int
+		header http.Header
+		body   string
+		want   error
+	}{
+		{http.StatusNotFound, http.Header{}, "", downloader.ErrNotFound},
+		{http.StatusTooManyRequests, http.Header{}, "", downloader.ErrRateLimited},
+		{http.StatusServiceUnavailable, http.Header{}, "busy", downloader.ErrRateLimited},
+		{http.StatusForbidden, http.Header{}, "<title>Just a moment...</title>", downloader.ErrChallenge},
+		{http.StatusOK, http.Header{"Cf-Mitigated": {"challenge"}}, "", downloader.ErrChallenge},
+	}
+	for _, c := range cases {
+		err := downloader.CheckResponse("https://example.com/", c.status, c.header, []byte(c.body))
+		if !errors.Is(fmt.Errorf("failed to get page: %w", err), c.want) {
+			t.Errorf("status %d: got %v, want %v", c.status, err, c.want)
+		}
+		var statusErr *downloader.StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != c.status {
+			t.Errorf("status %d: expected StatusError, got %v", c.status, err)
+		}
+	}
+	if err := downloader.CheckResponse("https://example.com/", http.StatusOK, http.Header{}, nil); err != nil {
+		t.Errorf("unexpected error for 200: %v", err)
+	}
+	err := downloader.CheckResponse("https://example.com/", http.StatusServiceUnavailable, http.Header{}, []byte("cf-chl-bypass"))
+	if errors.Is(err, downloader.ErrRateLimited) || !errors.Is(err, downloader.ErrChallenge) {
+		t.Errorf("challenge should not be treated as rate limit: %v", err)
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	err := fmt.Errorf("failed to get chapter: %w", &downloader.ParseError{Url: "https://example.com/", Selector: "#acontent", Reason: "content element not found"})
+	if !errors.Is(err, downloader.ErrParse) || errors.Is(err, downloader.ErrBrowser) {
+		t.Errorf("unexpected classification: %v", err)
+	}
+	var parseErr *downloader.ParseError
+	if !errors.As(err, &parseErr) || parseErr.Selector != "#acontent" {
+		t.Errorf("expected ParseError, got %v", err)
+	}
+
+	cause := errors.New("target closed")
+	err = fmt.Errorf("failed to render: %w", &downloader.BrowserError{Op: "render", Err: cause})
+	if !errors.Is(err, downloader.ErrBrowser) || !errors.Is(err, cause) {
+		t.Errorf("unexpected classification: %v", err)
+	}
+}
+
+func TestPartialError(t *testing.T) {
+	err := fmt.Errorf("failed to get novel: %w", &downloader.PartialError{
+		NovelId: 2388,
+		Volumes: []*downloader.VolumeError{
+			{VolumeId: 1, Err: &downloader.StatusError{Url: "https://example.com/1", StatusCode: http.StatusTooManyRequests}},
+			{VolumeId: 2, Err: &downloader.ParseError{Reason: "content element not found"}},
+		},
+	})
+	var partial *downloader.PartialError
+	if !errors.As(err, &partial) || len(partial.Volumes) != 2 {
+		t.Fatalf("expected PartialError, got %v", err)
+	}
+	if !errors.Is(err, downloader.ErrRateLimited) || !errors.Is(err, downloader.ErrParse) || errors.Is(err, downloader.ErrBrowser) {
+		t.Errorf("unexpected classification: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package test
+
+import (
+	"bilinovel-downloader/imageproc"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestProcessImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 3000, 1500))
+	for x := range 3000 {
+		src.Set(x, x%1500, color.RGBA{R: 255, A: 255})
+	}
+	buf := bytes.Buffer{}
+	err := png.Encode(&buf, src)
+	if err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+
+	profile, err := imageproc.Lookup("kindle-pw5")
+	if err != nil {
+		t.Fatalf("failed to lookup profile: %v", err)
+	}
+	profile.ForceJPEG = true
+	data, ext, err := imageproc.Process(buf.Bytes(), profile)
+	if err != nil {
+		t.Fatalf("failed to process image: %v", err)
+	}
+	if ext != ".jpg" {
+		t.Fatalf("unexpected ext: %v", ext)
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil || format != "jpeg" {
+		t.Fatalf("failed to decode processed image: %v %v", format, err)
+	}
+	if img.Bounds().Dx() != profile.MaxWidth || img.Bounds().Dy() != 618 {
+		t.Fatalf("unexpected size: %v", img.Bounds())
+	}
+	if _, ok := img.(*image.Gray); !ok {
+		t.Fatalf("expected grayscale image, got %T", img)
+	}
+
+	// 不需要处理的图片原样返回
+	data, ext, err = imageproc.Process(buf.Bytes(), imageproc.Profile{})
+	if err != nil || ext != "" || !bytes.Equal(data, buf.Bytes()) {
+		t.Fatalf("expected image unchanged")
+	}
+}
+
+func TestProcessTransparentImage(t *testing.T) {
+	// 左半部分透明，右半部分为不透明的黑色
+	src := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	for x := 10; x < 20; x++ {
+		for y := range 10 {
+			src.Set(x, y, color.NRGBA{A: 255})
+		}
+	}
+	buf := bytes.Buffer{}
+	err := png.Encode(&buf, src)
+	if err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		profile imageproc.Profile
+	}{
+		{"grayscale", imageproc.Profile{Grayscale: true}},
+		{"jpeg", imageproc.Profile{ForceJPEG: true}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data, _, err := imageproc.Process(buf.Bytes(), c.profile)
+			if err != nil {
+				t.Fatalf("failed to process image: %v", err)
+			}
+			img, _, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("failed to decode processed image: %v", err)
+			}
+			// 透明的部分合成到白色背景上
+			if y := color.GrayModel.Convert(img.At(2, 5)).(color.Gray).Y; y < 240 {
+				t.Fatalf("transparent area should be white, got %v", y)
+			}
+			if y := color.GrayModel.Convert(img.At(17, 5)).(color.Gray).Y; y > 15 {
+				t.Fatalf("opaque area should stay black, got %v", y)
+			}
+		})
+	}
+}
+
+// pngWithText 在 IHDR 之后插入 tEXt 块
+func pngWithText(data []byte) []byte {
+	text := []byte("tEXtComment\x00hello")
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(text)-4))
+	chunk = append(chunk, text...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(text))
+	// PNG 签名 8 字节，IHDR 块 25 字节
+	return append(append(append([]byte{}, data[:33]...), chunk...), data[33:]...)
+}
+
+func TestProcessStripMetadata(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	pngBuf := bytes.Buffer{}
+	err := png.Encode(&pngBuf, src)
+	if err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+	jpegBuf := bytes.Buffer{}
+	err = jpeg.Encode(&jpegBuf, src, nil)
+	if err != nil {
+		t.Fatalf("failed to encode jpeg: %v", err)
+	}
+
+	profile := imageproc.Profile{StripMetadata: true}
+	// 没有元数据的图片不重新编码
+	for _, data := range [][]byte{pngBuf.Bytes(), jpegBuf.Bytes()} {
+		processed, ext, err := imageproc.Process(data, profile)
+		if err != nil || ext != "" || !bytes.Equal(processed, data) {
+			t.Fatalf("expected image without metadata unchanged")
+		}
+	}
+
+	withText := pngWithText(pngBuf.Bytes())
+	if _, _, err := image.Decode(bytes.NewReader(withText)); err != nil {
+		t.Fatalf("invalid test png: %v", err)
+	}
+	processed, ext, err := imageproc.Process(withText, profile)
+	if err != nil || ext != ".png" {
+		t.Fatalf("failed to process image: %v %v", ext, err)
+	}
+	if bytes.Contains(processed, []byte("tEXt")) {
+		t.Fatalf("metadata should be stripped")
+	}
+}
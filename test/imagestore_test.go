@@ -0,0 +1,52 @@
+package test
+
+import (
+	"bilinovel-downloader/imagestore"
+	"bilinovel-downloader/model"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImageStore(t *testing.T) {
+	store := imagestore.New(t.TempDir())
+	name := imagestore.Name("https://img.example.com/a.jpg")
+	if store.Has(name) {
+		t.Fatalf("expected image not found")
+	}
+	err := store.Put(name, []byte{1, 2, 3})
+	if err != nil {
+		t.Fatalf("failed to put image: %v", err)
+	}
+	if !store.Has(name) {
+		t.Fatalf("expected image found")
+	}
+
+	dst := filepath.Join(t.TempDir(), "Images", name)
+	err = store.Link(name, dst)
+	if err != nil {
+		t.Fatalf("failed to link image: %v", err)
+	}
+	data, err := os.ReadFile(dst)
+	if err != nil || len(data) != 3 {
+		t.Fatalf("unexpected linked image: %v %v", data, err)
+	}
+
+	// 旧版本的卷内嵌图片数据，迁移后只保留文件名
+	volume := &model.Volume{
+		CoverUrl: "https://img.example.com/cover.jpg",
+		Cover:    []byte{4, 5},
+		Chapters: []*model.Chapter{{Content: &model.ChaperContent{Images: map[string][]byte{name: {1, 2, 3}}}}},
+	}
+	err = store.MigrateVolume(volume)
+	if err != nil {
+		t.Fatalf("failed to migrate volume: %v", err)
+	}
+	if volume.Cover != nil || !store.Has(volume.CoverImage) {
+		t.Fatalf("cover not migrated")
+	}
+	content := volume.Chapters[0].Content
+	if content.Images != nil || len(content.ImageNames) != 1 || content.ImageNames[0] != name {
+		t.Fatalf("chapter images not migrated: %+v", content)
+	}
+}
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"bilinovel-downloader/downloader/bilinovel"
 	"fmt"
 	"log"
 	"strings"
@@ -9,61 +10,6 @@ import (
 	"github.com/PuerkitoBio/goquery"
 )
 
-// unscrambleParagraphs 函数的核心功能是接收一个乱序的段落列表，
-// 并根据 chapterID 将它们重新排序为正确的阅读顺序。
-// 算法来源 https://www.bilinovel.com/themes/zhmb/js/chapterlog.js?v1006c1
-// 反混淆工具 https://obf-io.deobfuscate.io http://jsnice.org
-// 这个方案是可行的，但如果 bilinovel 频繁更改初始化种子的计算方式或算法的实现，会让排序方法失效，可能 playwright 还是最优解。
-func unscrambleParagraphs(scrambledParagraphs []*goquery.Selection, chapterID int) []*goquery.Selection {
-	j := len(scrambledParagraphs)
-	// 根据JS逻辑，如果段落数小于等于20，则不进行排序
-	if j <= 20 {
-		return scrambledParagraphs
-	}
-
-	// 1. 精确复刻JS中的伪随机数生成器和洗牌算法，以得到正确的索引映射关系。
-	// 初始化种子
-	ms := int64(chapterID*127 + 235)
-
-	// value 数组存放的是需要被打乱的、从20开始的段落的相对索引（0, 1, 2...）
-	value := make([]int, j-20)
-	for i := range value {
-		value[i] = i
-	}
-
-	// 执行与JS完全相同的 Fisher-Yates-like 洗牌算法
-	for i := len(value) - 1; i > 0; i-- {
-		ms = (ms*9302 + 49397) % 233280
-		prop := int(float64(ms) / 233280.0 * float64(i+1))
-		// 交换元素
-		value[i], value[prop] = value[prop], value[i]
-	}
-
-	// 2. 构建最终的索引映射表 (aProperties)。
-	// 这个表告诉我们，乱序列表中的每一项，应该被放到正确顺序列表的哪个位置。
-	aProperties := make([]int, j)
-	// 前20个段落顺序不变
-	for i := range 20 {
-		aProperties[i] = i
-	}
-	// 后续的段落使用洗牌后的索引，并加上20的偏移量
-	for i := range value {
-		aProperties[i+20] = value[i] + 20
-	}
-
-	// 3. 根据索引映射关系，从乱序列表中恢复出正确顺序。
-	// JS逻辑: elements[aProperties[i]] = out[i].node
-	// 翻译过来就是：乱序列表中的第 `i` 项 (scrambledParagraphs[i])，
-	// 它在最终排好序的列表中的正确位置应该是 `aProperties[i]`。
-	correctlyOrdered := make([]*goquery.Selection, j)
-	for i := range j {
-		correctPosition := aProperties[i]
-		correctlyOrdered[correctPosition] = scrambledParagraphs[i]
-	}
-
-	return correctlyOrdered
-}
-
 func TestResortDom(t *testing.T) {
 	// --- 步骤 1: 准备原始HTML ---
 	// 请将您用 http 请求获取到的、未经处理的完整HTML源码粘贴到这里。
@@ -308,7 +254,16 @@ var _hmt = _hmt || [];
 	fmt.Printf("从原始HTML中找到 %d 个乱序段落，准备重排。\n\n", len(scrambledParagraphs))
 
 	// --- 步骤 4: 执行重排算法 ---
-	correctlyOrderedParagraphs := unscrambleParagraphs(scrambledParagraphs, chapterID)
+	correctlyOrderedParagraphs := bilinovel.UnscrambleParagraphs(scrambledParagraphs, chapterID, bilinovel.DefaultUnscrambleParams)
+
+	if len(correctlyOrderedParagraphs) != len(scrambledParagraphs) {
+		t.Fatalf("段落数量不一致: %d != %d", len(correctlyOrderedParagraphs), len(scrambledParagraphs))
+	}
+	for i, p := range correctlyOrderedParagraphs {
+		if p == nil {
+			t.Fatalf("第 %d 个段落为空", i+1)
+		}
+	}
 
 	// --- 步骤 5: 输出最终结果 ---
 	fmt.Println("--- 已恢复正确顺序的最终内容 ---")
@@ -0,0 +1,40 @@
+package test
+
+import (
+	"bilinovel-downloader/model"
+	"bilinovel-downloader/quality"
+	"strings"
+	"testing"
+)
+
+func TestQualityCheck(t *testing.T) {
+	paragraphs := strings.Repeat("<p>这是一个足够长的正常段落，用来凑够章节的字数。</p>", 10)
+	volume := &model.Volume{
+		Id:    1,
+		Title: "第一卷",
+		Chapters: []*model.Chapter{
+			{Id: 1, Content: &model.ChaperContent{Html: paragraphs}},
+			{Id: 2, Content: &model.ChaperContent{Html: paragraphs + "<p>\ue001\ue002</p>"}},
+			{Id: 3, Content: &model.ChaperContent{Html: "<p>太短</p>"}},
+			{Id: 4, Content: &model.ChaperContent{Html: `<img src="a.jpg"/>`, ImageNames: []string{"a.jpg"}}},
+			{Id: 5},
+		},
+	}
+	report := quality.Check(volume, quality.DefaultThresholds)
+	kinds := make(map[int][]quality.IssueKind)
+	for _, issue := range report.Issues {
+		kinds[issue.ChapterId] = append(kinds[issue.ChapterId], issue.Kind)
+	}
+	if len(kinds[1]) != 0 || len(kinds[4]) != 0 {
+		t.Fatalf("unexpected issues: %v", report)
+	}
+	if len(kinds[2]) != 1 || kinds[2][0] != quality.IssuePUA {
+		t.Fatalf("expected pua issue: %v", report)
+	}
+	if len(kinds[3]) != 2 || kinds[3][0] != quality.IssueShort || kinds[3][1] != quality.IssueParagraphs {
+		t.Fatalf("expected short and paragraphs issues: %v", report)
+	}
+	if len(kinds[5]) != 1 || kinds[5][0] != quality.IssueMissing {
+		t.Fatalf("expected missing issue: %v", report)
+	}
+}
@@ -0,0 +1,30 @@
+package test
+
+import (
+	"bilinovel-downloader/utils"
+	"testing"
+)
+
+func TestParseRanges(t *testing.T) {
+	ranges, err := utils.ParseRanges("1-3, 7,10-")
+	if err != nil {
+		t.Fatalf("failed to parse ranges: %v", err)
+	}
+	for n, want := range map[int]bool{1: true, 3: true, 4: false, 7: true, 9: false, 10: true, 100: true} {
+		if ranges.Contains(n) != want {
+			t.Errorf("Contains(%d) = %v, want %v", n, !want, want)
+		}
+	}
+	// 区间不参与精确匹配
+	for n, want := range map[int]bool{2: false, 7: true, 10: false, 84522: false} {
+		if ranges.ContainsExact(n) != want {
+			t.Errorf("ContainsExact(%d) = %v, want %v", n, !want, want)
+		}
+	}
+	for _, invalid := range []string{"", "0", "3-1", "a-b", "-2"} {
+		_, err := utils.ParseRanges(invalid)
+		if err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
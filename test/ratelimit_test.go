@@ -0,0 +1,113 @@
+package test
+
+import (
+	"bilinovel-downloader/utils"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	cases := []struct {
+		value string
+		limit utils.RateLimit
+		ok    bool
+	}{
+		{"5", utils.RateLimit{Rate: 5}, true},
+		{"2.5:10", utils.RateLimit{Rate: 2.5, Burst: 10}, true},
+		{"0", utils.RateLimit{}, true},
+		{"-1", utils.RateLimit{}, false},
+		{"fast", utils.RateLimit{}, false},
+		{"1:x", utils.RateLimit{}, false},
+	}
+	for _, c := range cases {
+		limit, err := utils.ParseRateLimit(c.value)
+		if (err == nil) != c.ok || limit != c.limit {
+			t.Fatalf("unexpected result for %q: %+v %v", c.value, limit, err)
+		}
+	}
+}
+
+func TestRateLimitPerHost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := utils.NewRestyClient(10)
+	client.SetRateLimit("", utils.RateLimit{Rate: 20, Burst: 1})
+	start := time.Now()
+	for range 5 {
+		_, err := client.R(context.Background()).Get(server.URL)
+		if err != nil {
+			t.Fatalf("failed to get: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("requests were not rate limited: %v", elapsed)
+	}
+}
+
+func TestRateLimitAdaptive(t *testing.T) {
+	var throttle atomic.Bool
+	throttle.Store(true)
+	// 被限流的请求全部到达后才一起返回 429
+	var arrived sync.WaitGroup
+	arrived.Add(4)
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if throttle.Load() {
+			arrived.Done()
+			arrived.Wait()
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := utils.NewRestyClient(4)
+	client.SetRateLimit("", utils.RateLimit{Rate: 20, Burst: 20})
+	get := func(ctx context.Context, n int) {
+		var wg sync.WaitGroup
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _ = client.R(ctx).Get(server.URL)
+			}()
+		}
+		wg.Wait()
+	}
+
+	// 同时被限流的 4 个请求只使并发上限减半一次，重试等待时 ctx 超时
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	get(ctx, 4)
+	cancel()
+	throttle.Store(false)
+
+	get(context.Background(), 2)
+	if n := maxInFlight.Load(); n != 2 {
+		t.Fatalf("expected 2 requests in flight after throttling, got %v", n)
+	}
+
+	// 每连续成功 concurrency 个请求上限加一，直到恢复到初始的并发数
+	for range 3 {
+		get(context.Background(), 1)
+	}
+	maxInFlight.Store(0)
+	get(context.Background(), 4)
+	if n := maxInFlight.Load(); n != 4 {
+		t.Fatalf("expected 4 requests in flight after recovery, got %v", n)
+	}
+}
@@ -0,0 +1,38 @@
+package test
+
+import (
+	"bilinovel-downloader/downloader"
+	_ "bilinovel-downloader/downloader/bilinovel"
+	_ "bilinovel-downloader/downloader/linovelib"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	cases := []struct {
+		url      string
+		site     string
+		novelId  int
+		volumeId int
+	}{
+		{"https://www.bilinovel.com/novel/2388.html", "bilinovel", 2388, 0},
+		{"https://www.bilinovel.com/novel/2388/catalog", "bilinovel", 2388, 0},
+		{"https://www.bilinovel.com/novel/2388/vol_84522.html", "bilinovel", 2388, 84522},
+		{"https://www.bilinovel.com/novel/4126/236197_2.html", "bilinovel", 4126, 0},
+		{"https://www.linovelib.com/novel/4126/236197.html", "linovelib", 4126, 0},
+		{"https://tw.linovelib.com/novel/2388/vol_84522.html", "linovelib-tw", 2388, 84522},
+	}
+	for _, c := range cases {
+		target, err := downloader.Resolve(c.url)
+		if err != nil {
+			t.Fatalf("failed to resolve %v: %v", c.url, err)
+		}
+		if target.Site.Name != c.site || target.NovelId != c.novelId || target.VolumeId != c.volumeId {
+			t.Fatalf("unexpected target for %v: %v %v %v", c.url, target.Site.Name, target.NovelId, target.VolumeId)
+		}
+	}
+
+	_, err := downloader.Resolve("https://example.com/novel/1.html")
+	if err == nil {
+		t.Fatalf("expected error for unknown site")
+	}
+}
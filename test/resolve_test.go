@@ -0,0 +1,51 @@
+package test
+
+import (
+	"bilinovel-downloader/utils"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseHosts(t *testing.T) {
+	hosts, err := utils.ParseHosts(strings.NewReader(`
+# 注释
+203.0.113.10 www.bilinovel.com bilinovel.com
+127.0.0.1:8080 WWW.Linovelib.com # 本地测试服务器
+::1 ipv6.example.com
+`))
+	if err != nil {
+		t.Fatalf("failed to parse hosts: %v", err)
+	}
+	if hosts["www.bilinovel.com"] != "203.0.113.10" || hosts["bilinovel.com"] != "203.0.113.10" || hosts["www.linovelib.com"] != "127.0.0.1:8080" {
+		t.Fatalf("unexpected hosts: %v", hosts)
+	}
+	rules := utils.HostResolverRules(hosts)
+	if !strings.Contains(rules, "MAP www.bilinovel.com 203.0.113.10") || !strings.Contains(rules, "MAP ipv6.example.com [::1]") {
+		t.Fatalf("unexpected host resolver rules: %v", rules)
+	}
+
+	_, err = utils.ParseHosts(strings.NewReader("203.0.113.10\n"))
+	if err == nil {
+		t.Fatalf("expected error for line without host")
+	}
+}
+
+func TestHostOverride(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+
+	client := utils.NewRestyClient(1)
+	client.SetHosts(map[string]string{"novel.invalid": strings.TrimPrefix(server.URL, "http://")})
+	resp, err := client.R(context.Background()).Get("http://novel.invalid/")
+	if err != nil {
+		t.Fatalf("failed to get: %v", err)
+	}
+	if resp.String() != "novel.invalid" {
+		t.Fatalf("unexpected host header: %v", resp.String())
+	}
+}
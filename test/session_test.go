@@ -0,0 +1,65 @@
+package test
+
+import (
+	"bilinovel-downloader/session"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadCookies(t *testing.T) {
+	dir := t.TempDir()
+	netscape := filepath.Join(dir, "cookies.txt")
+	err := os.WriteFile(netscape, []byte(strings.Join([]string{
+		"# Netscape HTTP Cookie File",
+		".bilinovel.com\tTRUE\t/\tTRUE\t4102444800\tjieqiUserInfo\tabc",
+		"#HttpOnly_www.bilinovel.com\tFALSE\t/\tFALSE\t1\tPHPSESSID\told",
+		"",
+	}, "\n")), 0644)
+	if err != nil {
+		t.Fatalf("failed to write cookies: %v", err)
+	}
+	cookies, err := session.Load(netscape)
+	if err != nil {
+		t.Fatalf("failed to load netscape cookies: %v", err)
+	}
+	if len(cookies) != 2 || !cookies[1].HttpOnly || !cookies[0].Secure {
+		t.Fatalf("unexpected cookies: %v", cookies)
+	}
+	expired := session.Expired(cookies, time.Now())
+	if len(expired) != 1 || expired[0].Name != "PHPSESSID" {
+		t.Fatalf("unexpected expired cookies: %v", expired)
+	}
+
+	jar, err := session.NewJar(cookies)
+	if err != nil {
+		t.Fatalf("failed to create jar: %v", err)
+	}
+	got := jar.Cookies(&url.URL{Scheme: "https", Host: "www.bilinovel.com", Path: "/novel/1.html"})
+	if len(got) != 1 || got[0].Name != "jieqiUserInfo" {
+		t.Fatalf("unexpected jar cookies: %v", got)
+	}
+
+	exported := filepath.Join(dir, "cookies.json")
+	err = os.WriteFile(exported, []byte(`[{"domain": ".bilinovel.com", "name": "a", "value": "1", "path": "/", "expirationDate": 4102444800.5, "sameSite": "no_restriction"}]`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write cookies: %v", err)
+	}
+	cookies, err = session.Load(exported)
+	if err != nil || len(cookies) != 1 || cookies[0].Expires.Unix() != 4102444800 {
+		t.Fatalf("unexpected json cookies: %v %v", cookies, err)
+	}
+
+	state := filepath.Join(dir, "state.json")
+	err = os.WriteFile(state, []byte(`{"cookies": [{"domain": "www.bilinovel.com", "name": "b", "value": "2", "path": "/", "expires": -1}]}`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write cookies: %v", err)
+	}
+	cookies, err = session.Load(state)
+	if err != nil || len(cookies) != 1 || !cookies[0].Expires.IsZero() {
+		t.Fatalf("unexpected storage state cookies: %v %v", cookies, err)
+	}
+}
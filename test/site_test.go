@@ -0,0 +1,59 @@
+package test
+
+import (
+	"bilinovel-downloader/downloader"
+	"bilinovel-downloader/downloader/bilinovel"
+	"bilinovel-downloader/downloader/linovelib"
+	"testing"
+)
+
+func TestParseSiteDefinitionsYAML(t *testing.T) {
+	data := []byte(`
+- name: example
+  baseUrl: https://novel.example.com/
+  patterns:
+    - ^https?://novel\.example\.com/book/(?P<novel>\d+)
+  urls:
+    novel: /book/{novelId}.html
+    catalog: /book/{novelId}/catalog
+    volume: /book/{novelId}/vol_{volumeId}.html
+    chapter: /book/{novelId}/{chapterId}.html
+    chapterPage: /book/{novelId}/{chapterId}_{page}.html
+  selectors:
+    title: .book-title
+    volumeLink: a.volume-cover-img
+    chapterLink: .chapter-li a
+    chapterTitle: "#atitle"
+    content: "#acontent"
+`)
+	sites, err := bilinovel.ParseSiteDefinitionsYAML(data)
+	if err != nil {
+		t.Fatalf("failed to parse site definitions: %v", err)
+	}
+	if sites[0].BaseUrl != "https://novel.example.com" || sites[0].Language != "zh-CN" {
+		t.Fatalf("unexpected site definition: %v %v", sites[0].BaseUrl, sites[0].Language)
+	}
+
+	bilinovel.RegisterSiteDefinitions(sites)
+	target, err := downloader.Resolve("https://novel.example.com/book/42.html")
+	if err != nil {
+		t.Fatalf("failed to resolve: %v", err)
+	}
+	if target.Site.Name != "example" || target.NovelId != 42 {
+		t.Fatalf("unexpected target: %v %v", target.Site.Name, target.NovelId)
+	}
+
+	_, err = bilinovel.ParseSiteDefinitions([]byte(`[{"name": "broken", "baseUrl": "https://novel.example.com"}]`))
+	if err == nil {
+		t.Fatalf("expected error for incomplete site definition")
+	}
+}
+
+func TestBuiltinSitesHaveNoHosts(t *testing.T) {
+	// 主机覆盖只能通过命令行参数或者站点定义文件显式指定
+	for _, site := range []*bilinovel.SiteDefinition{bilinovel.DefaultSite(), linovelib.Site, linovelib.TwSite} {
+		if len(site.Hosts) > 0 {
+			t.Fatalf("built-in site %v should not override hosts: %v", site.Name, site.Hosts)
+		}
+	}
+}
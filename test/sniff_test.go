@@ -0,0 +1,47 @@
+package test
+
+import (
+	"bilinovel-downloader/imageproc"
+	"bilinovel-downloader/imagestore"
+	"bytes"
+	"image"
+	"image/png"
+	"path/filepath"
+	"testing"
+)
+
+func TestSniffImage(t *testing.T) {
+	buf := bytes.Buffer{}
+	err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1)))
+	if err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+	cases := []struct {
+		data      []byte
+		mediaType string
+		ext       string
+	}{
+		{buf.Bytes(), "image/png", ".png"},
+		{[]byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), "image/jpeg", ".jpg"},
+		{[]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), "image/webp", ".webp"},
+		{[]byte("\x00\x00\x00\x1cftypavif\x00\x00"), "image/avif", ".avif"},
+		{[]byte("<html></html>"), "", ""},
+	}
+	for _, c := range cases {
+		mediaType, ext := imageproc.Sniff(c.data)
+		if mediaType != c.mediaType || ext != c.ext {
+			t.Fatalf("unexpected sniff result for %q: %v %v", c.data, mediaType, ext)
+		}
+	}
+	if imageproc.MediaType(".JPEG") != "image/jpeg" {
+		t.Fatalf("unexpected media type for .JPEG")
+	}
+
+	// 查询参数不应进入文件名
+	if ext := filepath.Ext(imagestore.Name("https://img.example.com/a.webp?w=100")); ext != ".webp" {
+		t.Fatalf("unexpected image name ext: %v", ext)
+	}
+	if ext := filepath.Ext(imagestore.Name("https://img.example.com/image?id=1")); ext != "" {
+		t.Fatalf("unexpected image name ext: %v", ext)
+	}
+}
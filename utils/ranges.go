@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Range 闭区间，End 为 0 时表示没有上限
+type Range struct {
+	Start int
+	End   int
+}
+
+// Ranges 以逗号分隔的数字和区间，例如 1-3,7 或者 5-
+type Ranges []Range
+
+// ParseRanges 解析 1-3,7 格式的区间列表，5- 表示 5 及之后的所有数字
+func ParseRanges(s string) (Ranges, error) {
+	var ranges Ranges
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		startStr, endStr, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(startStr))
+		if err != nil || start <= 0 {
+			return nil, fmt.Errorf("invalid range: %v", part)
+		}
+		r := Range{Start: start, End: start}
+		if isRange {
+			endStr = strings.TrimSpace(endStr)
+			r.End = 0
+			if endStr != "" {
+				r.End, err = strconv.Atoi(endStr)
+				if err != nil || r.End < start {
+					return nil, fmt.Errorf("invalid range: %v", part)
+				}
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("invalid range: %v", s)
+	}
+	return ranges, nil
+}
+
+// Contains n 是否在任一区间中
+func (r Ranges) Contains(n int) bool {
+	for _, item := range r {
+		if n >= item.Start && (item.End == 0 || n <= item.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsExact n 是否等于某个单独的数字，区间不参与匹配
+func (r Ranges) ContainsExact(n int) bool {
+	for _, item := range r {
+		if item.Start == item.End && n == item.Start {
+			return true
+		}
+	}
+	return false
+}
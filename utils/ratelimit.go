@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimit 单个主机的请求速率限制
+type RateLimit struct {
+	// Rate 每秒的请求数，为 0 时不限制
+	Rate float64 `json:"rate" yaml:"rate"`
+	// Burst 令牌桶的容量，为 0 时与 Rate 相同（至少为 1）
+	Burst int `json:"burst" yaml:"burst"`
+}
+
+// ParseRateLimit 解析 rate[:burst] 格式的速率限制，例如 5 或者 2.5:10
+func ParseRateLimit(s string) (RateLimit, error) {
+	rateStr, burstStr, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+	r, err := strconv.ParseFloat(rateStr, 64)
+	if err != nil || r < 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit: %v", s)
+	}
+	limit := RateLimit{Rate: r}
+	if hasBurst {
+		limit.Burst, err = strconv.Atoi(burstStr)
+		if err != nil || limit.Burst < 0 {
+			return RateLimit{}, fmt.Errorf("invalid rate limit burst: %v", s)
+		}
+	}
+	return limit, nil
+}
+
+const (
+	// 被限流后速率降为当前的一半，但不低于配置速率的 minRateFactor
+	rateDecreaseFactor = 0.5
+	minRateFactor      = 0.05
+	// 每个成功的请求将速率提高配置速率的 rateIncreaseFactor，直到恢复到配置速率
+	rateIncreaseFactor = 0.02
+)
+
+// hostLimiter 单个主机的令牌桶和并发上限，收到 429 或者 503 时按 AIMD 方式降低速率并将并发上限减半，
+// 之后随着成功的请求逐步恢复。同时进行的请求往往一起被限流，每个冷却时间内只降低一次
+type hostLimiter struct {
+	mu      sync.Mutex
+	limiter *rate.Limiter
+	max     rate.Limit
+	min     rate.Limit
+
+	// concurrency 当前的并发上限，每连续成功 concurrency 个请求加一，直到恢复到 maxConcurrency
+	concurrency    int
+	maxConcurrency int
+	active         int
+	successes      int
+	// lastDecrease 上次降低速率的时间，之后的 1/rate 秒内收到的限流响应不再降低
+	lastDecrease time.Time
+	// released 有并发槽释放时关闭并替换，用于唤醒等待的请求
+	released chan struct{}
+}
+
+func newHostLimiter(limit RateLimit, concurrency int) *hostLimiter {
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = max(int(limit.Rate), 1)
+	}
+	concurrency = max(concurrency, 1)
+	return &hostLimiter{
+		limiter:        rate.NewLimiter(rate.Limit(limit.Rate), burst),
+		max:            rate.Limit(limit.Rate),
+		min:            rate.Limit(limit.Rate * minRateFactor),
+		concurrency:    concurrency,
+		maxConcurrency: concurrency,
+		released:       make(chan struct{}),
+	}
+}
+
+func (l *hostLimiter) feedback(status int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	current := l.limiter.Limit()
+	switch {
+	case status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable:
+		l.successes = 0
+		if time.Since(l.lastDecrease) < time.Duration(float64(time.Second)/float64(current)) {
+			return
+		}
+		l.limiter.SetLimit(max(current*rateDecreaseFactor, l.min))
+		l.concurrency = max(l.concurrency/2, 1)
+		l.lastDecrease = time.Now()
+	case status < 400:
+		if current < l.max {
+			l.limiter.SetLimit(min(current+l.max*rateIncreaseFactor, l.max))
+		}
+		if l.concurrency < l.maxConcurrency {
+			l.successes++
+			if l.successes >= l.concurrency {
+				l.concurrency++
+				l.successes = 0
+			}
+		}
+	}
+}
+
+// acquire 等待主机的并发槽，ctx 取消时立即返回
+func (l *hostLimiter) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.active < l.concurrency {
+			l.active++
+			l.mu.Unlock()
+			return nil
+		}
+		released := l.released
+		l.mu.Unlock()
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (l *hostLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// rateLimiter 按主机限制请求速率和并发数，没有单独配置的主机使用默认配置
+type rateLimiter struct {
+	mu       sync.Mutex
+	fallback RateLimit
+	limits   map[string]RateLimit
+	hosts    map[string]*hostLimiter
+	// concurrency 每个主机的最大并发数
+	concurrency int
+}
+
+func newRateLimiter(concurrency int) *rateLimiter {
+	return &rateLimiter{
+		limits:      make(map[string]RateLimit),
+		hosts:       make(map[string]*hostLimiter),
+		concurrency: concurrency,
+	}
+}
+
+func (r *rateLimiter) set(host string, limit RateLimit) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if host == "" {
+		r.fallback = limit
+		// 已经创建的使用默认配置的主机需要重新创建
+		for h := range r.hosts {
+			if _, ok := r.limits[h]; !ok {
+				delete(r.hosts, h)
+			}
+		}
+		return
+	}
+	host = strings.ToLower(host)
+	r.limits[host] = limit
+	delete(r.hosts, host)
+}
+
+// host 返回主机的令牌桶，不限制速率时返回 nil
+func (r *rateLimiter) host(host string) *hostLimiter {
+	host = strings.ToLower(host)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l, ok := r.hosts[host]
+	if ok {
+		return l
+	}
+	limit, ok := r.limits[host]
+	if !ok {
+		limit = r.fallback
+	}
+	if limit.Rate > 0 {
+		l = newHostLimiter(limit, r.concurrency)
+	}
+	r.hosts[host] = l
+	return l
+}
+
+// acquire 等待主机的令牌和并发槽，返回释放并发槽的函数，ctx 取消时立即返回
+func (r *rateLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	l := r.host(host)
+	if l == nil {
+		return func() {}, nil
+	}
+	err := l.limiter.Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = l.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return l.release, nil
+}
+
+// feedback 根据响应状态调整主机的速率
+func (r *rateLimiter) feedback(host string, status int) {
+	l := r.host(host)
+	if l != nil {
+		l.feedback(status)
+	}
+}
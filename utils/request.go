@@ -2,8 +2,12 @@ package utils
 
 import (
 	"context"
+	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-resty/resty/v2"
@@ -13,6 +17,9 @@ type RestyClient struct {
 	client      *resty.Client
 	concurrency int
 	sem         chan struct{}
+	rate        *rateLimiter
+	dialer      *hostDialer
+	proxy       *proxySelector
 }
 
 func NewRestyClient(concurrency int) *RestyClient {
@@ -20,53 +27,140 @@ func NewRestyClient(concurrency int) *RestyClient {
 		client:      resty.New(),
 		concurrency: concurrency,
 		sem:         make(chan struct{}, concurrency),
+		rate:        newRateLimiter(concurrency),
+		dialer: &hostDialer{
+			hosts:  make(map[string]string),
+			dialer: net.Dialer{Timeout: 10 * time.Second},
+		},
+		proxy: &proxySelector{hosts: make(map[string]*url.URL)},
 	}
-	client.client.SetTransport(&http.Transport{
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			if addr == "www.bilinovel.com:443" {
-				addr = "64.140.161.52:443"
-			}
-			return (&net.Dialer{
-				Timeout: 10 * time.Second,
-			}).DialContext(ctx, network, addr)
+	client.client.SetTransport(&limitedTransport{
+		sem:  client.sem,
+		rate: client.rate,
+		base: &http.Transport{
+			Proxy:               client.proxy.proxy,
+			DialContext:         client.dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
 		},
-		TLSHandshakeTimeout: 10 * time.Second,
 	})
-	client.client.
-		OnBeforeRequest(func(c *resty.Client, req *resty.Request) error {
-			client.sem <- struct{}{}
-			return nil
-		}).
-		OnAfterResponse(func(c *resty.Client, resp *resty.Response) error {
-			<-client.sem
-			return nil
-		})
 	client.client.SetRetryCount(10).
-		SetRetryWaitTime(3 * time.Second).
+		SetRetryWaitTime(time.Second).
+		SetRetryMaxWaitTime(30 * time.Second).
 		SetRetryAfter(func(client *resty.Client, resp *resty.Response) (time.Duration, error) {
-			if resp.StatusCode() == http.StatusTooManyRequests {
-				if retryAfter := resp.Header().Get("Retry-After"); retryAfter != "" {
-					if seconds, err := time.ParseDuration(retryAfter + "s"); err == nil {
-						return seconds, nil
-					}
-					if t, err := http.ParseTime(retryAfter); err == nil {
-						return time.Until(t), nil
-					}
+			if resp == nil || !throttled(resp.StatusCode()) {
+				// 返回 0 时使用带抖动的指数退避
+				return 0, nil
+			}
+			if retryAfter := resp.Header().Get("Retry-After"); retryAfter != "" {
+				if seconds, err := time.ParseDuration(retryAfter + "s"); err == nil {
+					return seconds, nil
+				}
+				if t, err := http.ParseTime(retryAfter); err == nil {
+					return time.Until(t), nil
 				}
-				return 3 * time.Second, nil
 			}
 			return 0, nil
 		}).
 		AddRetryCondition(func(r *resty.Response, err error) bool {
-			return err != nil || r.StatusCode() == http.StatusTooManyRequests
+			return err != nil || throttled(r.StatusCode())
 		})
 
 	client.client.SetLogger(disableLogger{}).SetHeader("Accept-Charset", "utf-8").SetHeader("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:133.0) Gecko/20100101 Firefox/133.0")
 	return client
 }
 
-func (c *RestyClient) R() *resty.Request {
-	return c.client.R()
+// SetTimeout 设置单次请求的超时时间，0 表示不超时
+func (c *RestyClient) SetTimeout(timeout time.Duration) {
+	c.client.SetTimeout(timeout)
+}
+
+// SetRateLimit 设置主机每秒的请求数，host 为空时设置所有没有单独配置的主机。
+// 收到 429 或者 503 时会自动降低速率和主机的并发数，之后逐步恢复到设置的速率和客户端的并发数
+func (c *RestyClient) SetRateLimit(host string, limit RateLimit) {
+	c.rate.set(host, limit)
+}
+
+// SetHosts 设置主机覆盖，连接 host 时改为连接对应的地址（可以带端口），与 hosts 文件的作用相同
+func (c *RestyClient) SetHosts(hosts map[string]string) {
+	c.dialer.mu.Lock()
+	defer c.dialer.mu.Unlock()
+	for host, addr := range hosts {
+		c.dialer.hosts[strings.ToLower(host)] = addr
+	}
+}
+
+// SetResolver 设置没有主机覆盖时使用的解析器，为空时使用系统解析
+func (c *RestyClient) SetResolver(resolver Resolver) {
+	c.dialer.mu.Lock()
+	defer c.dialer.mu.Unlock()
+	c.dialer.resolver = resolver
+}
+
+// SetProxy 设置代理，host 为空时设置默认代理，否则只对该主机（以点开头时为域名后缀）生效。
+// proxy 为 ProxyDirect 时直接连接，没有设置默认代理时使用环境变量中的代理
+func (c *RestyClient) SetProxy(host string, proxy string) error {
+	return c.proxy.set(host, proxy)
+}
+
+// SetCookieJar 设置请求使用的 cookie jar，响应中设置的 cookie 也会保存在其中
+func (c *RestyClient) SetCookieJar(jar http.CookieJar) {
+	c.client.SetCookieJar(jar)
+}
+
+// throttled 判断响应是否表示请求被限流
+func throttled(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable
+}
+
+// R 创建绑定 ctx 的请求，ctx 取消时请求、重试等待和并发槽的等待都会立即返回
+func (c *RestyClient) R(ctx context.Context) *resty.Request {
+	return c.client.R().SetContext(ctx)
+}
+
+// limitedTransport 限制同时进行的请求数量和每个主机的请求速率与并发数，在 RoundTrip 中获取和释放并发槽，
+// 请求失败或者被取消时也能保证并发槽被释放
+type limitedTransport struct {
+	base http.RoundTripper
+	sem  chan struct{}
+	rate *rateLimiter
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	releaseHost, err := t.rate.acquire(req.Context(), req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case t.sem <- struct{}{}:
+	case <-req.Context().Done():
+		releaseHost()
+		return nil, req.Context().Err()
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		<-t.sem
+		releaseHost()
+		return nil, err
+	}
+	t.rate.feedback(req.URL.Hostname(), resp.StatusCode)
+	// 响应体读取完毕后才释放并发槽
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: func() {
+		<-t.sem
+		releaseHost()
+	}}
+	return resp, nil
+}
+
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
 }
 
 type disableLogger struct{}
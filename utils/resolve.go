@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver 将主机名解析为地址，*net.Resolver 实现了这个接口，也可以替换为其他实现
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// NewDNSResolver 创建使用指定 DNS 服务器的解析器，server 为 host:port，省略端口时使用 53
+func NewDNSResolver(server string) *net.Resolver {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return (&net.Dialer{Timeout: 10 * time.Second}).DialContext(ctx, network, server)
+		},
+	}
+}
+
+// ParseHosts 解析 hosts 文件格式的主机覆盖，每行为 "地址 主机名..."，# 之后为注释。
+// 地址可以带端口（例如 127.0.0.1:8080），用于将站点指向本地的测试服务器
+func ParseHosts(r io.Reader) (map[string]string, error) {
+	hosts := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid hosts line %d: %q", line, scanner.Text())
+		}
+		for _, host := range fields[1:] {
+			hosts[strings.ToLower(host)] = fields[0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read hosts: %w", err)
+	}
+	return hosts, nil
+}
+
+// LoadHosts 读取 hosts 文件
+func LoadHosts(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open hosts file: %w", err)
+	}
+	defer file.Close()
+	return ParseHosts(file)
+}
+
+// HostResolverRules 将主机覆盖转换为 Chromium --host-resolver-rules 参数的值，没有覆盖时返回空字符串
+func HostResolverRules(hosts map[string]string) string {
+	rules := make([]string, 0, len(hosts))
+	for host, addr := range hosts {
+		// IPv6 地址需要加上方括号
+		if ip := net.ParseIP(addr); ip != nil && ip.To4() == nil {
+			addr = "[" + addr + "]"
+		}
+		rules = append(rules, fmt.Sprintf("MAP %s %s", host, addr))
+	}
+	slices.Sort(rules)
+	return strings.Join(rules, ", ")
+}
+
+// hostDialer 建立连接前先查找主机覆盖，没有覆盖时使用配置的解析器，都没有时使用系统解析
+type hostDialer struct {
+	mu       sync.RWMutex
+	hosts    map[string]string
+	resolver Resolver
+	dialer   net.Dialer
+}
+
+func (d *hostDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+	d.mu.RLock()
+	override, ok := d.hosts[strings.ToLower(host)]
+	resolver := d.resolver
+	d.mu.RUnlock()
+
+	if ok {
+		if _, _, err := net.SplitHostPort(override); err == nil {
+			return d.dialer.DialContext(ctx, network, override)
+		}
+		return d.dialer.DialContext(ctx, network, net.JoinHostPort(override, port))
+	}
+	if resolver == nil || net.ParseIP(host) != nil {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+
+	addrs, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %v: %w", host, err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("failed to resolve %v: no address", host)
+	}
+	for _, a := range addrs {
+		var conn net.Conn
+		conn, err = d.dialer.DialContext(ctx, network, net.JoinHostPort(a, port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}